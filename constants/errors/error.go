@@ -8,6 +8,17 @@ const (
 	RequestError
 	MsgError
 	HotReloadError
+	FriendError
+	UploadError
+	UploadSizeError
+	SendError
+	LoginExpiredError
+	FrequencyLimitError
+	RevokeError
+	RevokeTimeoutError
+	RecipientNotFoundError
+	RecipientAmbiguousError
+	ScheduleError
 )
 
 func (l TypeError) New() *WeChatError {
@@ -33,6 +44,28 @@ func (l TypeError) ErrorType() string {
 		return "MsgError"
 	case HotReloadError:
 		return "HotReloadError"
+	case FriendError:
+		return "FriendError"
+	case UploadError:
+		return "UploadError"
+	case UploadSizeError:
+		return "UploadSizeError"
+	case SendError:
+		return "SendError"
+	case LoginExpiredError:
+		return "LoginExpiredError"
+	case FrequencyLimitError:
+		return "FrequencyLimitError"
+	case RevokeError:
+		return "RevokeError"
+	case RevokeTimeoutError:
+		return "RevokeTimeoutError"
+	case RecipientNotFoundError:
+		return "RecipientNotFoundError"
+	case RecipientAmbiguousError:
+		return "RecipientAmbiguousError"
+	case ScheduleError:
+		return "ScheduleError"
 	}
 	return "UNKNOWN"
 }
@@ -49,6 +82,39 @@ func (l TypeError) Msg() string {
 		return "获取信息错误"
 	case HotReloadError:
 		return "热重启失败"
+	case FriendError:
+		return "好友操作失败"
+	case UploadError:
+		return "上传文件失败"
+	case UploadSizeError:
+		return "文件大小超过限制"
+	case SendError:
+		return "发送消息失败"
+	case LoginExpiredError:
+		return "登录已失效"
+	case FrequencyLimitError:
+		return "操作太频繁"
+	case RevokeError:
+		return "撤回消息失败"
+	case RevokeTimeoutError:
+		return "超过撤回时限"
+	case RecipientNotFoundError:
+		return "未找到接收者"
+	case RecipientAmbiguousError:
+		return "匹配到多个接收者"
+	case ScheduleError:
+		return "定时任务错误"
 	}
 	return "-"
 }
+
+// 根据接口返回的BaseResponse.Ret获取错误类型
+func RetError(ret int) TypeError {
+	switch ret {
+	case 1100, 1101, 1102:
+		return LoginExpiredError
+	case 1205:
+		return FrequencyLimitError
+	}
+	return SendError
+}
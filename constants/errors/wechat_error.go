@@ -6,6 +6,8 @@ type WeChatError struct {
 	ErrorType string
 	Msg string
 	Desc string
+	// 接口返回的错误码
+	Code int
 }
 
 func (w *WeChatError) WithMsg(msg string) *WeChatError {
@@ -18,6 +20,17 @@ func (w *WeChatError) WithDesc(desc string) *WeChatError {
 	return w
 }
 
+func (w *WeChatError) WithCode(code int) *WeChatError {
+	w.Code = code
+	return w
+}
+
+// 判断是否为指定类型的错误，可配合标准库errors.Is使用
+func (w *WeChatError) Is(target error) bool {
+	t, ok := target.(TypeError)
+	return ok && w.ErrorType == t.ErrorType()
+}
+
 func (w WeChatError) Error() string {
 	return fmt.Sprintf("[%s]msg:%s,desc:%s", w.ErrorType, w.Msg, w.Desc)
 }
\ No newline at end of file
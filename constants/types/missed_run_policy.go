@@ -0,0 +1,9 @@
+package types
+
+// 定时任务错过执行时间的处理策略
+type MissedRunPolicy int
+
+const (
+	MISSED_RUN_SKIP     MissedRunPolicy = iota // 跳过错过的执行，等待下次执行时间
+	MISSED_RUN_CATCH_UP                        // 立即补发一次
+)
@@ -0,0 +1,12 @@
+package types
+
+type MsgEventType int
+
+const (
+	MSG_EVENT_NORMAL        MsgEventType = iota // 普通消息
+	MSG_EVENT_REVOKED                           // 消息撤回
+	MSG_EVENT_MEMBER_JOINED                     // 成员加入群聊
+	MSG_EVENT_MEMBER_LEFT                       // 成员离开群聊
+	MSG_EVENT_GROUP_RENAMED                     // 群聊改名
+	MSG_EVENT_FRIEND_ADDED                      // 添加好友
+)
@@ -37,6 +37,16 @@ type WXUrlBase struct {
 	WebWXSendMsgImgUrl string
 	// 发送视频消息
 	WebWXSendVideoMsgUrl string
+	// 好友验证
+	WebWXVerifyUserUrl string
+	// 获取消息图片
+	WebWXGetMsgImgUrl string
+	// 发送表情消息
+	WebWXSendEmoticonUrl string
+	// 发送app消息，文件附件等
+	WebWXSendAppMsgUrl string
+	// 撤回消息
+	WebWXRevokeMsgUrl string
 }
 
 type CryptConf struct {
@@ -91,6 +101,11 @@ var Common = struct {
 		WebWXUploadMediaUrl:  HostFile + "/cgi-bin/mmwebwx-bin/webwxuploadmedia", // /cgi-bin/mmwebwx-bin/webwxuploadmedia?f=json
 		WebWXSendMsgImgUrl:   HostWx + "/cgi-bin/mmwebwx-bin/webwxsendmsgimg",    // /cgi-bin/mmwebwx-bin/webwxsendmsgimg?fun=async&f=json&lang=zh_CN&pass_ticket=<pass_ticket>
 		WebWXSendVideoMsgUrl: HostWx + "/cgi-bin/mmwebwx-bin/webwxsendvideomsg",  // /cgi-bin/mmwebwx-bin/webwxsendvideomsg?fun=async&f=json
+		WebWXVerifyUserUrl:   HostWx + "/cgi-bin/mmwebwx-bin/webwxverifyuser",    // /cgi-bin/mmwebwx-bin/webwxverifyuser?r=<r>&lang=zh_CN&pass_ticket=<pass_ticket>
+		WebWXGetMsgImgUrl:    HostWx + "/cgi-bin/mmwebwx-bin/webwxgetmsgimg",     // /cgi-bin/mmwebwx-bin/webwxgetmsgimg?MsgID=<msgid>&skey=<skey>&type=big
+		WebWXSendEmoticonUrl: HostWx + "/cgi-bin/mmwebwx-bin/webwxsendemoticon",  // /cgi-bin/mmwebwx-bin/webwxsendemoticon?fun=sys&lang=zh_CN&pass_ticket=<pass_ticket>
+		WebWXSendAppMsgUrl:   HostWx + "/cgi-bin/mmwebwx-bin/webwxsendappmsg",    // /cgi-bin/mmwebwx-bin/webwxsendappmsg?fun=async&f=json&lang=zh_CN&pass_ticket=<pass_ticket>
+		WebWXRevokeMsgUrl:    HostWx + "/cgi-bin/mmwebwx-bin/webwxrevokemsg",     // /cgi-bin/mmwebwx-bin/webwxrevokemsg?lang=zh_CN&pass_ticket=<pass_ticket>
 
 	},
 
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/constants/types"
+	"github.com/oliverCJ/go-wechat/util"
+)
+
+const (
+	// 群发默认发送间隔
+	defaultBroadcastInterval = 3 * time.Second
+	// 公众号及订阅号的VerifyFlag标记
+	officialVerifyFlag = 8
+)
+
+// 群发接收者筛选条件，多个条件同时设置时需全部满足
+type BroadcastFilter struct {
+	// 联系人类型，为空时为好友和群组，指定接收者时为空表示不限制
+	ContactTypes []types.ContactType
+	// 名称匹配规则，匹配备注名、昵称或群名
+	NamePattern *regexp.Regexp
+	// 指定接收者，可以是id或名称，为空时从所有联系人中筛选
+	Recipients []string
+	// 从所有联系人中筛选时是否包含公众号，默认不包含
+	IncludeOfficial bool
+}
+
+// 群发配置
+type BroadcastOption struct {
+	// 两次发送的间隔，为0时使用默认值
+	Interval time.Duration
+	// 每次间隔随机增加的最大时长，避免固定频率发送
+	Jitter time.Duration
+}
+
+// 单个接收者的群发结果
+type BroadcastResult struct {
+	// 接收者id，查找接收者失败时为空
+	ToUserName string
+	// 接收者名称
+	Name string
+	// 发送结果
+	Result SendResult
+	// 发送失败原因，成功时为nil
+	Err error
+}
+
+// 群发文本消息，按间隔依次发送给筛选出的接收者，返回每个接收者的发送结果
+// 与发送队列共用发送频率控制，两次发送至少间隔SendInterval
+// ctx取消、触发频率限制或登录失效时停止发送，未发送的接收者结果为对应的错误
+func (msg *MsgServices) Broadcast(ctx context.Context, content string, filter BroadcastFilter, option BroadcastOption) ([]BroadcastResult, error) {
+	if option.Interval <= 0 {
+		option.Interval = defaultBroadcastInterval
+	}
+
+	results := msg.broadcastRecipients(filter)
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			failRemaining(results[i:], err)
+			return results, err
+		}
+
+		msg.sendLimiter.wait(msg.option.SendInterval)
+		results[i].Result, results[i].Err = msg.sendMsg(SendMessage{
+			ToUserName: results[i].ToUserName,
+			Content:    content,
+		})
+		// 频率限制和登录失效时继续发送只会加重账号风险
+		if e, ok := results[i].Err.(*errors.WeChatError); ok && (e.Is(errors.FrequencyLimitError) || e.Is(errors.LoginExpiredError)) {
+			failRemaining(results[i+1:], e)
+			return results, e
+		}
+
+		if i < len(results)-1 {
+			wait := option.Interval
+			if option.Jitter > 0 {
+				wait += time.Duration(rand.Int63n(int64(option.Jitter)))
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}
+	return results, nil
+}
+
+// 将尚未发送的接收者结果设为指定错误
+func failRemaining(results []BroadcastResult, err error) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+	}
+}
+
+// 按筛选条件获取接收者，查找失败的指定接收者也会出现在结果中
+func (msg *MsgServices) broadcastRecipients(filter BroadcastFilter) []BroadcastResult {
+	contactTypes := map[types.ContactType]bool{}
+	for _, contactType := range filter.ContactTypes {
+		contactTypes[contactType] = true
+	}
+	if len(contactTypes) == 0 && len(filter.Recipients) == 0 {
+		contactTypes[types.CONTACT_TYPE_MEMBER] = true
+		contactTypes[types.CONTACT_TYPE_GROUP] = true
+	}
+	memberMap := msg.UserData.Members()
+	match := func(member TinyMemberInfo) bool {
+		if member.UserName == msg.UserData.UserInfo.UserName {
+			return false
+		}
+		if len(contactTypes) > 0 && !contactTypes[member.Type] {
+			return false
+		}
+		if filter.NamePattern != nil {
+			return filter.NamePattern.MatchString(util.NormalizeContent(member.RemarkName)) ||
+				filter.NamePattern.MatchString(util.NormalizeContent(member.NickName))
+		}
+		return true
+	}
+
+	results := []BroadcastResult{}
+	if len(filter.Recipients) > 0 {
+		added := map[string]bool{}
+		for _, name := range filter.Recipients {
+			userName, err := msg.InitService.ResolveRecipient(name)
+			if err != nil {
+				results = append(results, BroadcastResult{Name: name, Err: err})
+				continue
+			}
+			if added[userName] {
+				continue
+			}
+			// 不在联系人中的id（如filehelper）不参与类型和名称筛选
+			if member, ok := memberMap[userName]; ok && !match(member) {
+				continue
+			}
+			added[userName] = true
+			results = append(results, BroadcastResult{ToUserName: userName, Name: name})
+		}
+		return results
+	}
+
+	for userName, member := range memberMap {
+		if member.VerifyFlag&officialVerifyFlag != 0 && !filter.IncludeOfficial {
+			continue
+		}
+		if match(member) {
+			results = append(results, BroadcastResult{ToUserName: userName, Name: msg.InitService.GetMemberName(userName, "")})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"encoding/xml"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/oliverCJ/go-wechat/constants/types"
 )
@@ -34,14 +36,39 @@ type BaseUserData struct {
 	SyncCheckKeyStr string
 	// 订阅信息
 	MPSubscribeMsgList []MPSubscribeMsg
-	// 所有联系人汇总
+	// 所有联系人汇总，读写时需持有memberLock
 	GlobalMemberMap map[string]TinyMemberInfo
+	// 联系人汇总的读写锁，群组成员列表写入后不再修改，更新时整体替换
+	memberLock sync.RWMutex
+}
+
+// 获取联系人信息
+func (u *BaseUserData) GetMember(userName string) (TinyMemberInfo, bool) {
+	u.memberLock.RLock()
+	defer u.memberLock.RUnlock()
+	member, ok := u.GlobalMemberMap[userName]
+	return member, ok
+}
+
+// 获取所有联系人的副本
+func (u *BaseUserData) Members() map[string]TinyMemberInfo {
+	u.memberLock.RLock()
+	defer u.memberLock.RUnlock()
+	members := make(map[string]TinyMemberInfo, len(u.GlobalMemberMap))
+	for userName, member := range u.GlobalMemberMap {
+		members[userName] = member
+	}
+	return members
 }
 
 // 精简联系人信息（主要是为了构建全局MAP，便于查找）
 type TinyMemberInfo struct {
-	UserName       string
-	NickName       string
+	UserName string
+	NickName string
+	// 备注名
+	RemarkName string
+	// 微信号
+	Alias          string
 	DisplayName    string
 	HeadImgUrl     string
 	Sex            int
@@ -52,6 +79,8 @@ type TinyMemberInfo struct {
 	Type           types.ContactType
 	GroupMemberMap map[string]User
 	MemberCount    int
+	// 群组加密id，查询群成员详情时使用
+	EncryChatRoomId string
 }
 
 // 公参
@@ -197,12 +226,17 @@ type Message struct {
 	FromUserName string
 	// 消息接收者
 	ToUserName string
+	// 会话对象，当前登录用户发送的消息为接收者，否则为发送者
+	ChatUserName string
+	// 是否为当前登录用户在其他设备发送的消息
+	IsSelf bool
 	// 消息真实发送者
 	RealUserName string
 	// 消息类型
-	MsgType       int
-	PlayLength    int
-	RecommendInfo []string
+	MsgType    int
+	PlayLength int
+	// 好友请求信息，仅好友请求消息有值
+	RecommendInfo RecommendInfo
 	// 消息内容
 	Content string
 	// 格式化后的消息
@@ -226,10 +260,159 @@ type Message struct {
 	ToUserNickName   string
 	// 消息真实发送者
 	RealUserNickName string
+	// 消息真实发送者展示名称，按群昵称、备注名、昵称的优先级获取
+	RealUserDisplayName string
 	// 消息发送的群名
 	FromGroupNickName string
 	// 消息创建时间
 	CreateTime int32
+	// 消息事件类型
+	Event types.MsgEventType
+	// 撤回消息信息，仅Event为MSG_EVENT_REVOKED时有值，解析失败时为nil，原始内容保留在Content中
+	RevokeInfo *RevokeInfo
+	// 系统通知信息，仅成员变动、群聊改名、添加好友事件时有值
+	SysNoticeInfo *SysNoticeInfo
+	// 名片信息，仅名片消息有值
+	CardInfo *CardInfo
+	// 位置信息，仅定位消息有值
+	LocationInfo *LocationInfo
+	// 表情信息，仅表情消息有值
+	EmoticonInfo *EmoticonInfo
+	// 群组消息中被@的成员id
+	MentionedUserNames []string
+	// 群组消息中是否@了当前登录用户
+	IsMentioningMe bool
+	// 原始消息数据
+	Raw map[string]interface{}
+}
+
+// 撤回消息信息
+type RevokeInfo struct {
+	// 被撤回的消息id
+	MsgId int64
+	// 撤回提示
+	ReplaceMsg string
+	// 被撤回的原始消息，缓存中没有找到时为nil
+	OriginMsg *Message
+}
+
+// 系统通知信息
+type SysNoticeInfo struct {
+	// 操作者昵称
+	Operator string
+	// 操作者id
+	OperatorUserName string
+	// 涉及的成员昵称
+	Members []string
+	// 涉及的成员id，与Members一一对应，未找到的成员为空字符串
+	MemberUserNames []string
+	// 群聊名称，改名事件为新的群名
+	GroupName string
+}
+
+// 名片信息
+type CardInfo struct {
+	UserName string
+	NickName string
+	Alias    string
+	Province string
+	City     string
+	Sex      int
+}
+
+// 位置信息
+type LocationInfo struct {
+	// 纬度
+	Latitude float64
+	// 经度
+	Longitude float64
+	// 地址描述
+	Label string
+	// 地点名称
+	PoiName string
+	// 地图链接
+	Url string
+}
+
+// 表情信息
+type EmoticonInfo struct {
+	// 表情md5，可用于重新发送
+	Md5 string
+	// 表情下载地址
+	CdnUrl string
+	Width  int
+	Height int
+	// 商店表情的产品id
+	ProductId string
+	// 是否为自定义表情，否则为商店表情
+	IsCustom bool
+}
+
+// 名片消息内容
+type CardMsgXml struct {
+	XMLName  xml.Name `xml:"msg"`
+	UserName string   `xml:"username,attr"`
+	NickName string   `xml:"nickname,attr"`
+	Alias    string   `xml:"alias,attr"`
+	Province string   `xml:"province,attr"`
+	City     string   `xml:"city,attr"`
+	Sex      int      `xml:"sex,attr"`
+}
+
+// 定位消息内容
+type LocationMsgXml struct {
+	XMLName  xml.Name `xml:"msg"`
+	Location struct {
+		X       float64 `xml:"x,attr"`
+		Y       float64 `xml:"y,attr"`
+		Scale   int     `xml:"scale,attr"`
+		Label   string  `xml:"label,attr"`
+		PoiName string  `xml:"poiname,attr"`
+	} `xml:"location"`
+}
+
+// 表情消息内容
+type EmojiMsgXml struct {
+	XMLName xml.Name `xml:"msg"`
+	Emoji   struct {
+		Md5       string `xml:"md5,attr"`
+		CdnUrl    string `xml:"cdnurl,attr"`
+		Width     int    `xml:"width,attr"`
+		Height    int    `xml:"height,attr"`
+		ProductId string `xml:"productid,attr"`
+	} `xml:"emoji"`
+}
+
+// 撤回消息内容
+type RevokeMsgXml struct {
+	XMLName   xml.Name `xml:"sysmsg"`
+	Type      string   `xml:"type,attr"`
+	RevokeMsg struct {
+		Session    string `xml:"session"`
+		OldMsgId   string `xml:"oldmsgid"`
+		MsgId      string `xml:"msgid"`
+		ReplaceMsg string `xml:"replacemsg"`
+	} `xml:"revokemsg"`
+}
+
+// 好友请求信息
+type RecommendInfo struct {
+	UserName string
+	NickName string
+	QQNum    int64
+	Province string
+	City     string
+	// 验证消息
+	Content    string
+	Signature  string
+	Alias      string
+	Scene      int
+	VerifyFlag int
+	AttrStatus int64
+	Sex        int
+	// 通过验证需要的ticket
+	Ticket string
+	OpCode int
 }
 
 type AppInfo struct {
@@ -238,6 +421,7 @@ type AppInfo struct {
 }
 
 type SendMessage struct {
+	// 接收者id，也可以使用备注名、昵称、微信号或群名
 	ToUserName string
 	// 消息内容
 	Content string
@@ -246,7 +430,20 @@ type SendMessage struct {
 }
 
 type SendMessageResp struct {
-	BaseRequest BaseRequest
-	MsgID       string
-	LocalID     string
+	BaseResponse BaseResponse
+	MsgID        string
+	LocalID      string
+}
+
+// 消息发送结果
+type SendResult struct {
+	ToUserName string
+	// 服务端消息id
+	MsgID string
+	// 本地id
+	LocalID string
+	// 客户端消息id
+	ClientMsgId string
+	// 发送时间
+	SendTime time.Time
 }
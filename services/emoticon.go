@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 下载表情文件，优先使用表情的cdn地址，没有时通过消息id获取
+func (msg *MsgServices) DownloadEmoticon(message Message, w io.Writer) error {
+	var (
+		resp       []byte
+		statusCode int
+		err        error
+	)
+	if message.EmoticonInfo != nil && message.EmoticonInfo.CdnUrl != "" {
+		resp, statusCode, err = msg.Request.RequestWithStatus(http.MethodGet, message.EmoticonInfo.CdnUrl, nil, util.FORM_HEADER)
+	} else {
+		params := url.Values{}
+		params.Set("MsgID", strconv.FormatInt(message.MsgId, 10))
+		params.Set("skey", msg.LoginData.BaseRequest.Skey)
+		params.Set("type", "big")
+		resp, statusCode, err = msg.CheckRequest.RequestWithStatus(http.MethodGet, global.Common.WXUrlBase.WebWXGetMsgImgUrl, params, util.FORM_HEADER)
+	}
+	if err != nil {
+		logrus.Warningf("下载表情失败[msgId:%d, err:%s]", message.MsgId, err.Error())
+		return errors.MsgError.New().WithMsg("下载表情失败").WithDesc(err.Error())
+	}
+	if statusCode != http.StatusOK {
+		logrus.Warningf("下载表情失败[msgId:%d, status:%d]", message.MsgId, statusCode)
+		return errors.MsgError.New().WithMsg("下载表情失败").WithDesc(fmt.Sprintf("[status:%d]", statusCode))
+	}
+
+	_, err = w.Write(resp)
+	if err != nil {
+		logrus.Warningf("保存表情失败[msgId:%d, err:%s]", message.MsgId, err.Error())
+		return errors.MsgError.New().WithMsg("保存表情失败").WithDesc(err.Error())
+	}
+	return nil
+}
+
+// 通过md5发送表情，返回发送结果
+func (msg *MsgServices) SendEmoticon(toUserName, md5 string) (SendResult, error) {
+	toUserName, err := msg.InitService.ResolveRecipient(toUserName)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("fun", "sys")
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	clientMsgId := newClientMsgId()
+	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.WebWXSendEmoticonUrl, params.Encode())
+	return msg.postSendMsg(urlPath, toUserName, clientMsgId, struct {
+		Type         int
+		EmojiFlag    int
+		EMoticonMd5  string
+		FromUserName string
+		ToUserName   string
+		LocalID      string
+		ClientMsgId  string
+	}{
+		Type:         47,
+		EmojiFlag:    2,
+		EMoticonMd5:  md5,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   toUserName,
+		LocalID:      clientMsgId,
+		ClientMsgId:  clientMsgId,
+	})
+}
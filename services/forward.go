@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+)
+
+// 可以转发的app消息类型
+var forwardAppMsgTypes = map[int]bool{
+	5:  true, // 链接
+	6:  true, // 文件
+	33: true, // 小程序
+	36: true, // 小程序
+}
+
+// 转发收到的消息，文本直接发送，图片、视频、文件复用MediaId，链接和小程序重新发送appmsg内容
+// 返回结果与toUserNames一一对应，发送失败的为空结果，有失败时返回第一个错误
+func (msg *MsgServices) Forward(message Message, toUserNames ...string) ([]SendResult, error) {
+	results := make([]SendResult, len(toUserNames))
+	var firstErr error
+	for i, toUserName := range toUserNames {
+		result, err := msg.forward(message, toUserName)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results[i] = result
+	}
+	return results, firstErr
+}
+
+func (msg *MsgServices) forward(message Message, toUserName string) (SendResult, error) {
+	toUserName, err := msg.InitService.ResolveRecipient(toUserName)
+	if err != nil {
+		return SendResult{}, err
+	}
+	// 图片、视频和文件需要复用原消息的MediaId
+	if message.MediaId == "" && (message.MsgType == 3 || message.MsgType == 43 || (message.MsgType == 49 && message.AppMsgType == 6)) {
+		return SendResult{}, errors.MsgError.New().WithMsg("不支持转发的消息类型").
+			WithDesc(fmt.Sprintf("消息没有MediaId[msgId:%d, msgType:%d, appMsgType:%d]", message.MsgId, message.MsgType, message.AppMsgType))
+	}
+
+	switch message.MsgType {
+	case 1:
+		return msg.sendMsg(SendMessage{
+			ToUserName: toUserName,
+			Content:    util.NormalizeContent(stripGroupSender(message.Content)),
+		})
+	case 3:
+		return msg.forwardMedia(message, toUserName, 3, unescapeXml(message.Content), global.Common.WXUrlBase.WebWXSendMsgImgUrl)
+	case 43:
+		return msg.forwardMedia(message, toUserName, 43, unescapeXml(message.Content), global.Common.WXUrlBase.WebWXSendVideoMsgUrl)
+	case 47:
+		if message.EmoticonInfo != nil && message.EmoticonInfo.Md5 != "" {
+			return msg.SendEmoticon(toUserName, message.EmoticonInfo.Md5)
+		}
+	case 49:
+		if !forwardAppMsgTypes[message.AppMsgType] {
+			break
+		}
+		content := appMsgContent(message.Content)
+		if content == "" {
+			break
+		}
+		msgType := 49
+		if message.AppMsgType == 6 {
+			msgType = 6
+		}
+		return msg.forwardMedia(message, toUserName, msgType, content, global.Common.WXUrlBase.WebWXSendAppMsgUrl)
+	}
+	return SendResult{}, errors.MsgError.New().WithMsg("不支持转发的消息类型").
+		WithDesc(fmt.Sprintf("[msgId:%d, msgType:%d, appMsgType:%d]", message.MsgId, message.MsgType, message.AppMsgType))
+}
+
+// 复用原消息的MediaId及内容发送媒体消息
+func (msg *MsgServices) forwardMedia(message Message, toUserName string, msgType int, content, sendUrl string) (SendResult, error) {
+	params := url.Values{}
+	params.Set("fun", "async")
+	params.Set("f", "json")
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	clientMsgId := newClientMsgId()
+	urlPath := fmt.Sprintf("%s?%s", sendUrl, params.Encode())
+	return msg.postSendMsg(urlPath, toUserName, clientMsgId, mediaMsg{
+		Type:         msgType,
+		MediaId:      message.MediaId,
+		Content:      content,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   toUserName,
+		LocalID:      clientMsgId,
+		ClientMsgId:  clientMsgId,
+	})
+}
+
+// 从app消息中取出appmsg节点
+func appMsgContent(content string) string {
+	content = unescapeXml(content)
+	start := strings.Index(content, "<appmsg")
+	end := strings.LastIndex(content, "</appmsg>")
+	if start < 0 || end < start {
+		return ""
+	}
+	return content[start : end+len("</appmsg>")]
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 好友验证操作类型
+const (
+	// 发送好友请求
+	verifyOpcodeAdd = 2
+	// 通过好友请求
+	verifyOpcodeAccept = 3
+)
+
+// 通过好友请求，ticket来自好友请求消息的RecommendInfo
+func (msg *MsgServices) AcceptFriend(userName, ticket string) error {
+	return msg.verifyUser(verifyOpcodeAccept, userName, ticket, "")
+}
+
+// 发送好友请求
+func (msg *MsgServices) AddFriend(userName, verifyContent string) error {
+	return msg.verifyUser(verifyOpcodeAdd, userName, "", verifyContent)
+}
+
+// 好友验证
+func (msg *MsgServices) verifyUser(opcode int, userName, ticket, verifyContent string) error {
+	params := url.Values{}
+	params.Set("r", strconv.FormatInt(time.Now().Unix(), 10))
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	type verifyUser struct {
+		Value            string
+		VerifyUserTicket string
+	}
+
+	bodyParams, _ := json.Marshal(struct {
+		BaseRequest        *BaseRequest
+		Opcode             int
+		VerifyUserListSize int
+		VerifyUserList     []verifyUser
+		VerifyContent      string
+		SceneListCount     int
+		SceneList          []int
+		Skey               string `json:"skey"`
+	}{
+		BaseRequest:        msg.LoginData.BaseRequest,
+		Opcode:             opcode,
+		VerifyUserListSize: 1,
+		VerifyUserList: []verifyUser{
+			{
+				Value:            userName,
+				VerifyUserTicket: ticket,
+			},
+		},
+		VerifyContent:  verifyContent,
+		SceneListCount: 1,
+		SceneList:      []int{33},
+		Skey:           msg.LoginData.BaseRequest.Skey,
+	})
+
+	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.WebWXVerifyUserUrl, params.Encode())
+	resp, err := msg.Request.Request(http.MethodPost, urlPath, bodyParams, util.JSON_HEADER)
+	if err != nil {
+		logrus.Warningf("好友验证失败[user:%s, err:%s]", userName, err.Error())
+		return errors.FriendError.New().WithMsg("好友验证失败").WithDesc(err.Error())
+	}
+
+	respData := struct {
+		BaseResponse BaseResponse
+	}{}
+	err = json.Unmarshal(resp, &respData)
+	if err != nil {
+		logrus.Warningf("好友验证返回数据解析失败[resp:%s, err:%s]", string(resp), err.Error())
+		return errors.FriendError.New().WithMsg("好友验证返回数据解析失败").WithDesc(err.Error())
+	}
+	if respData.BaseResponse.Ret != 0 {
+		logrus.Warningf("好友验证失败,接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg)
+		return errors.FriendError.New().WithMsg("好友验证失败").WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
+	}
+
+	if opcode == verifyOpcodeAccept {
+		// 更新新好友信息
+		err = msg.InitService.BatchGetContactInfo([]string{userName})
+		if err != nil {
+			logrus.Warningf("获取新好友信息失败[user:%s, err:%s]", userName, err.Error())
+		}
+	}
+	return nil
+}
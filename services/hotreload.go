@@ -80,7 +80,9 @@ func (h *HotReloadService) CacheLogin() error {
 			},
 			UserData: h.userData,
 		}
+		h.userData.memberLock.RLock()
 		loginDataByte, err := json.Marshal(cacheStruct)
+		h.userData.memberLock.RUnlock()
 		if err != nil {
 			logrus.Warningf("格式化用户信息失败[err:%s]", err.Error())
 			// 不中断
@@ -100,7 +102,7 @@ func (h *HotReloadService) CacheLogin() error {
 	return nil
 }
 
-func LoadLogin(rootDir string, autoReply bool, msgRead chan Message, msgSend chan SendMessage, msgSendResp chan SendMessageResp) (*BaseLoginData, *BaseUserData, bool, error) {
+func LoadLogin(rootDir string, option MsgOption, msgRead chan Message, msgSend chan SendMessage, msgSendResp chan SendMessageResp) (*BaseLoginData, *BaseUserData, bool, error) {
 
 	resp, err := util.LoadCacheData(rootDir + "/auth.record")
 	if err != nil {
@@ -143,7 +145,7 @@ func LoadLogin(rootDir string, autoReply bool, msgRead chan Message, msgSend cha
 	// 尝试获取消息
 	initService := NewInitService(loginData)
 	initService.BaseUserData = oldCacheData.UserData
-	msgService := NewMsgService(initService, autoReply, msgRead, msgSend, msgSendResp)
+	msgService := NewMsgService(initService, option, msgRead, msgSend, msgSendResp)
 	err = msgService.SyncMsg()
 	if err != nil {
 		logrus.Warningf("热重启拉取消息发生错误[err:%s]", err.Error())
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/oliverCJ/go-wechat/constants/errors"
@@ -19,6 +21,12 @@ import (
 
 // 初始化相关
 
+// 批量获取联系人接口单次最多查询50个，分批时的最大并发数
+const (
+	batchGetContactSize        = 50
+	batchGetContactConcurrency = 4
+)
+
 type InitService struct {
 	// 基础登录数据
 	LoginData *BaseLoginData
@@ -26,6 +34,9 @@ type InitService struct {
 	BaseUserData *BaseUserData
 	// 请求资源
 	Request *util.Request
+	// 查询失败的群组成员，避免重复请求，重新获取联系人时清空
+	missingLock    sync.Mutex
+	missingMembers map[string]bool
 }
 
 func NewInitService(data *BaseLoginData) *InitService {
@@ -34,7 +45,8 @@ func NewInitService(data *BaseLoginData) *InitService {
 		BaseUserData: &BaseUserData{
 			GlobalMemberMap: make(map[string]TinyMemberInfo),
 		},
-		Request: util.NewRequest(),
+		Request:        util.NewRequest(),
+		missingMembers: make(map[string]bool),
 	}
 }
 
@@ -100,8 +112,8 @@ func (init *InitService) loginInit() error {
 		BaseRequest: init.LoginData.BaseRequest,
 	})
 	if err != nil {
-		logrus.Warningf("登录初始化失败，格式化请求参数失败[param:%s,err:%s]", init.LoginData.BaseRequest, err.Error())
-		return errors.InitLoginError.New().WithMsg("登录初始化失败").WithDesc(fmt.Sprintf("格式化请求参数失败[param:%s,err:%s]", init.LoginData.BaseRequest, err.Error()))
+		logrus.Warningf("登录初始化失败，格式化请求参数失败[param:%+v,err:%s]", init.LoginData.BaseRequest, err.Error())
+		return errors.InitLoginError.New().WithMsg("登录初始化失败").WithDesc(fmt.Sprintf("格式化请求参数失败[param:%+v,err:%s]", init.LoginData.BaseRequest, err.Error()))
 	}
 
 	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.LoginInitUrl, params.Encode())
@@ -145,46 +157,23 @@ func (init *InitService) loginInit() error {
 	init.BaseUserData.UserInfo = respData.User
 
 	if len(respData.ContactList) > 0 {
+		groupNames := []string{}
+		init.BaseUserData.memberLock.Lock()
 		for _, item := range respData.ContactList {
-			temp := TinyMemberInfo{
-				UserName:    item.UserName,
-				NickName:    item.NickName,
-				DisplayName: item.DisplayName,
-				HeadImgUrl:  item.HeadImgUrl,
-				Sex:         item.Sex,
-				Signature:   item.Signature,
-				VerifyFlag:  item.VerifyFlag,
-				Province:    item.Province,
-				City:        item.City,
-				MemberCount: item.MemberCount,
-			}
-
-			if len(item.MemberList) > 0 {
-				groupMemberMap := make(map[string]User)
-				for _, v := range item.MemberList {
-					groupMemberMap[v.UserName] = v
-				}
-				temp.GroupMemberMap = groupMemberMap
-			}
-
-			if _, ok := global.Common.SpecialUsers[item.UserName]; ok {
-				temp.Type = types.CONTACT_TYPE_SPECIAL
-			} else if item.UserName[:2] == "@@" { // 群组
-				temp.Type = types.CONTACT_TYPE_GROUP
-			} else if item.UserName[:1] == "@" {
-				temp.Type = types.CONTACT_TYPE_MEMBER
-			} else {
-				temp.Type = types.CONTACT_TYPE_UNKONWN
-			}
-
+			temp := newTinyMemberInfo(item)
 			init.BaseUserData.GlobalMemberMap[item.UserName] = temp
 			init.BaseUserData.ChatList = append(init.BaseUserData.ChatList, item)
 			if temp.Type == types.CONTACT_TYPE_GROUP {
-				// 通讯录的群组需要单独查询组员信息
-				init.BatchGetContactInfo([]string{item.UserName})
+				groupNames = append(groupNames, item.UserName)
 			}
 		}
+		init.BaseUserData.memberLock.Unlock()
+		// 通讯录的群组需要单独查询组员信息
+		if err := init.BatchGetContactInfo(groupNames); err != nil {
+			logrus.Warningf("获取群组成员失败[err:%s]", err.Error())
+		}
 	}
+	init.BaseUserData.memberLock.Lock()
 	for _, v := range global.Common.SpecialUsers {
 		temp := TinyMemberInfo{
 			UserName:    v,
@@ -193,6 +182,7 @@ func (init *InitService) loginInit() error {
 		}
 		init.BaseUserData.GlobalMemberMap[v] = temp
 	}
+	init.BaseUserData.memberLock.Unlock()
 
 	init.BaseUserData.MPSubscribeMsgList = respData.MPSubscribeMsgList
 	init.BaseUserData.SyncCheckKey = respData.SyncKey
@@ -211,13 +201,31 @@ func (init *InitService) loginInit() error {
 	return nil
 }
 
+// 状态通知类型
+const (
+	// 标记会话已读
+	statusNotifyCodeRead = 1
+	// 开启状态通知
+	statusNotifyCodeInit = 3
+)
+
 // 开启状态通知
 func (init *InitService) statusNotify() error {
+	err := init.notifyStatus(statusNotifyCodeInit, init.BaseUserData.UserInfo.UserName)
+	if err != nil {
+		logrus.Warningf("开启状态通知失败[err:%s]", err.Error())
+		return errors.InitLoginError.New().WithMsg("开启状态通知失败").WithDesc(err.Error())
+	}
+	return nil
+}
+
+// 发送状态通知
+func (init *InitService) notifyStatus(code int, toUserName string) error {
 	params := url.Values{}
 	params.Set("lang", global.Common.Lang)
 	params.Set("pass_ticket", init.LoginData.BaseRequest.PassTicket)
 
-	// 开启状态通知请求参数
+	// 状态通知请求参数
 	type StatusNotifyRequest struct {
 		BaseRequest  *BaseRequest `json:"BaseRequest"`
 		Code         int          `json:"Code"`
@@ -225,118 +233,97 @@ func (init *InitService) statusNotify() error {
 		ToUserName   string       `json:"ToUserName"`
 		ClientMsgId  int32        `json:"ClientMsgId"`
 	}
-	// 开启状态通知返回参数
+	// 状态通知返回参数
 	type NotifyResp struct {
-		BaseResponse *BaseResponse `json:"BaseResponse"`
-		MsgID        string        `json:"MsgID"`
+		BaseResponse BaseResponse `json:"BaseResponse"`
+		MsgID        string       `json:"MsgID"`
 	}
 
 	BodyParams, _ := json.Marshal(StatusNotifyRequest{
 		BaseRequest:  init.LoginData.BaseRequest,
-		Code:         3,
+		Code:         code,
 		FromUserName: init.BaseUserData.UserInfo.UserName,
-		ToUserName:   init.BaseUserData.UserInfo.UserName,
+		ToUserName:   toUserName,
 		ClientMsgId:  int32(time.Now().Unix()),
 	})
 
 	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.LoginStatusNotifyUrl, params.Encode())
 	resp, err := init.Request.Request(http.MethodPost, urlPath, BodyParams, util.JSON_HEADER)
 	if err != nil {
-		logrus.Warningf("开启状态通知失败[err:%s]", err.Error())
-		return errors.InitLoginError.New().WithMsg("开启状态通知失败").WithDesc(err.Error())
+		return errors.RequestError.New().WithDesc(err.Error())
 	}
 
 	respData := new(NotifyResp)
-	_ = json.Unmarshal(resp, respData)
+	err = json.Unmarshal(resp, respData)
+	if err != nil {
+		return errors.RequestError.New().WithMsg("状态通知返回数据解析失败").WithDesc(err.Error())
+	}
 
 	if respData.BaseResponse.Ret != 0 {
-		logrus.Warningf("开启状态通知失败,接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg)
-		return errors.InitLoginError.New().WithMsg("开启状态通知失败").WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
+		return errors.RequestError.New().WithCode(respData.BaseResponse.Ret).
+			WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
 	}
-
 	return nil
 }
 
-// 获取联系人
+// 获取联系人，联系人较多时分页获取，合并所有分页后再分类
 func (init *InitService) GetContact() error {
-	params := url.Values{}
-	params.Set("pass_ticket", init.LoginData.BaseRequest.PassTicket)
-	params.Set("skey", init.LoginData.BaseRequest.Skey)
-	params.Set("r", strconv.FormatInt(time.Now().Unix(), 10))
-
-	BodyParams, _ := json.Marshal(struct {
-		BaseRequest *BaseRequest `json:"BaseRequest"`
-	}{
-		BaseRequest: init.LoginData.BaseRequest,
-	})
-
-	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.LoginContactUrl, params.Encode())
-	resp, err := init.Request.Request(http.MethodPost, urlPath, BodyParams, util.JSON_HEADER)
-	if err != nil {
-		logrus.Warningf("获取联系人失败[err:%s]", err.Error())
-		return errors.InitLoginError.New().WithMsg("获取联系人失败").WithDesc(err.Error())
-	}
-
-	type MemberResp struct {
-		BaseResponse *BaseResponse `json:"BaseResponse"`
-		MemberCount  int           `json:"MemberCount"`
-		MemberList   []Member      `json:"MemberList"`
-		Seq          int           `json:"Seq"`
-	}
-
-	respData := new(MemberResp)
-
-	_ = json.Unmarshal(resp, respData)
-	if respData.BaseResponse.Ret != 0 {
-		logrus.Warningf("获取联系人失败,接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg)
-		return errors.InitLoginError.New().WithMsg("获取联系人失败").WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
-	}
-
-	// 处理联系人
-	if respData.MemberCount > 0 {
-
-		for _, item := range respData.MemberList {
-			temp := TinyMemberInfo{
-				UserName:    item.UserName,
-				NickName:    item.NickName,
-				DisplayName: item.DisplayName,
-				HeadImgUrl:  item.HeadImgUrl,
-				Sex:         item.Sex,
-				Signature:   item.Signature,
-				VerifyFlag:  item.VerifyFlag,
-				Province:    item.Province,
-				City:        item.City,
-				MemberCount: item.MemberCount,
-
-			}
-			if len(item.MemberList) > 0 {
-				groupMemberMap := make(map[string]User)
-				for _, v := range item.MemberList {
-					groupMemberMap[v.UserName] = v
-				}
-				temp.GroupMemberMap = groupMemberMap
-			}
+	memberList := []Member{}
+	seq := 0
+	for {
+		list, nextSeq, err := init.getContactPage(seq)
+		if err != nil {
+			return err
+		}
+		memberList = append(memberList, list...)
+		if nextSeq == 0 || nextSeq == seq {
+			break
+		}
+		seq = nextSeq
+	}
+
+	init.missingLock.Lock()
+	init.missingMembers = make(map[string]bool)
+	init.missingLock.Unlock()
+
+	// 处理联系人，重新获取时覆盖原有列表
+	init.BaseUserData.ContactList = ContactList{}
+	groupNames := []string{}
+	init.BaseUserData.memberLock.Lock()
+	for _, item := range memberList {
+		temp := newTinyMemberInfo(item)
+		if item.UserName[:2] == "@@" { // 群组
+			temp.Type = types.CONTACT_TYPE_GROUP
+			init.BaseUserData.ContactList.Group = append(init.BaseUserData.ContactList.Group, item)
+		} else if item.UserName[:1] == "@" { // 联系人
+			temp.Type = types.CONTACT_TYPE_MEMBER
+			init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
+		} else if _, ok := global.Common.SpecialUsers[item.UserName]; ok {
+			temp.Type = types.CONTACT_TYPE_SPECIAL
+			init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
+		} else {
+			temp.Type = types.CONTACT_TYPE_UNKONWN
+			init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
+		}
 
-			if item.UserName[:2] == "@@" { // 群组
-				temp.Type = types.CONTACT_TYPE_GROUP
-				init.BaseUserData.ContactList.Group = append(init.BaseUserData.ContactList.Group, item)
-			} else if item.UserName[:1] == "@" { // 联系人
-				temp.Type = types.CONTACT_TYPE_MEMBER
-				init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
-			} else if _, ok := global.Common.SpecialUsers[item.UserName]; ok {
-				temp.Type = types.CONTACT_TYPE_SPECIAL
-				init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
-			} else {
-				temp.Type = types.CONTACT_TYPE_UNKONWN
-				init.BaseUserData.ContactList.MemberList = append(init.BaseUserData.ContactList.MemberList, item)
+		// 通讯录接口不返回群组成员，保留已获取的成员，避免重新查询失败时丢失
+		if old, ok := init.BaseUserData.GlobalMemberMap[item.UserName]; ok {
+			if len(temp.GroupMemberMap) == 0 {
+				temp.GroupMemberMap = old.GroupMemberMap
 			}
-
-			init.BaseUserData.GlobalMemberMap[item.UserName] = temp
-			if temp.Type == types.CONTACT_TYPE_GROUP {
-				// 通讯录的群组需要单独查询组员信息
-				init.BatchGetContactInfo([]string{item.UserName})
+			if temp.EncryChatRoomId == "" {
+				temp.EncryChatRoomId = old.EncryChatRoomId
 			}
 		}
+		init.BaseUserData.GlobalMemberMap[item.UserName] = temp
+		if temp.Type == types.CONTACT_TYPE_GROUP {
+			groupNames = append(groupNames, item.UserName)
+		}
+	}
+	init.BaseUserData.memberLock.Unlock()
+	// 通讯录的群组需要单独查询组员信息
+	if err := init.BatchGetContactInfo(groupNames); err != nil {
+		logrus.Warningf("获取群组成员失败[err:%s]", err.Error())
 	}
 
 	// 处理chatlist
@@ -347,7 +334,7 @@ func (init *InitService) GetContact() error {
 	// 映射chatset
 	if len(init.BaseUserData.ChatSet) > 0 {
 		for _, v := range init.BaseUserData.ChatSet {
-			if value, ok := init.BaseUserData.GlobalMemberMap[v]; ok {
+			if value, ok := init.BaseUserData.GetMember(v); ok {
 				chatListMap[value.UserName] = Member{
 					UserName:    value.UserName,
 					NickName:    value.NickName,
@@ -371,11 +358,180 @@ func (init *InitService) GetContact() error {
 	return nil
 }
 
-// 批量获取联系人详情
+// 获取一页联系人，返回下一页的seq，为0时表示没有更多联系人
+func (init *InitService) getContactPage(seq int) ([]Member, int, error) {
+	params := url.Values{}
+	params.Set("pass_ticket", init.LoginData.BaseRequest.PassTicket)
+	params.Set("skey", init.LoginData.BaseRequest.Skey)
+	params.Set("seq", strconv.Itoa(seq))
+	params.Set("r", strconv.FormatInt(time.Now().Unix(), 10))
+
+	BodyParams, _ := json.Marshal(struct {
+		BaseRequest *BaseRequest `json:"BaseRequest"`
+	}{
+		BaseRequest: init.LoginData.BaseRequest,
+	})
+
+	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.LoginContactUrl, params.Encode())
+	resp, err := init.Request.Request(http.MethodPost, urlPath, BodyParams, util.JSON_HEADER)
+	if err != nil {
+		logrus.Warningf("获取联系人失败[seq:%d, err:%s]", seq, err.Error())
+		return nil, 0, errors.InitLoginError.New().WithMsg("获取联系人失败").WithDesc(err.Error())
+	}
+
+	type MemberResp struct {
+		BaseResponse BaseResponse `json:"BaseResponse"`
+		MemberCount  int          `json:"MemberCount"`
+		MemberList   []Member     `json:"MemberList"`
+		Seq          int          `json:"Seq"`
+	}
+
+	respData := new(MemberResp)
+	err = json.Unmarshal(resp, respData)
+	if err != nil {
+		logrus.Warningf("获取联系人解析失败[seq:%d, err:%s]", seq, err.Error())
+		return nil, 0, errors.InitLoginError.New().WithMsg("获取联系人解析失败").WithDesc(err.Error())
+	}
+	if respData.BaseResponse.Ret != 0 {
+		logrus.Warningf("获取联系人失败,接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg)
+		return nil, 0, errors.InitLoginError.New().WithMsg("获取联系人失败").WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
+	}
+	return respData.MemberList, respData.Seq, nil
+}
+
+// 批量获取联系人详情，超过50个时分批并发查询，部分批次失败时仍会更新成功获取的联系人
 func (init *InitService) BatchGetContactInfo(ids []string) error {
-	if len(ids) == 0 || len(ids) > 50 {
+	if len(ids) == 0 {
 		return nil
 	}
+	list := []map[string]string{}
+	for _, v := range ids {
+		list = append(list, map[string]string{
+			"UserName":        v,
+			"EncryChatRoomId": "",
+		})
+	}
+
+	contactList, err := init.batchGetContactChunked(list)
+
+	init.BaseUserData.memberLock.Lock()
+	defer init.BaseUserData.memberLock.Unlock()
+	for _, v := range contactList {
+		if temp, ok := init.BaseUserData.GlobalMemberMap[v.UserName]; ok {
+			if v.UserName[:2] == "@@" {
+				if len(v.MemberList) > 0 {
+					groupMemberMap := make(map[string]User)
+					for _, v2 := range v.MemberList {
+						groupMemberMap[v2.UserName] = v2
+					}
+					temp.GroupMemberMap = groupMemberMap
+				}
+				if v.EncryChatRoomId != "" {
+					temp.EncryChatRoomId = v.EncryChatRoomId
+				}
+			}
+			temp.DisplayName = v.DisplayName
+			temp.NickName = v.NickName
+			temp.RemarkName = v.RemarkName
+			temp.Alias = v.Alias
+			temp.MemberCount = v.MemberCount
+			init.BaseUserData.GlobalMemberMap[v.UserName] = temp
+		} else {
+			// 新加入的好友或群组
+			init.BaseUserData.GlobalMemberMap[v.UserName] = newTinyMemberInfo(v)
+		}
+	}
+	return err
+}
+
+// 批量获取群组成员详情，需要使用群组的EncryChatRoomId，超过50个时分批并发查询
+func (init *InitService) BatchGetGroupMemberInfo(groupName string, userNames []string) error {
+	group, ok := init.BaseUserData.GetMember(groupName)
+	if !ok || len(userNames) == 0 {
+		return nil
+	}
+	list := []map[string]string{}
+	for _, v := range userNames {
+		list = append(list, map[string]string{
+			"UserName":        v,
+			"EncryChatRoomId": group.EncryChatRoomId,
+		})
+	}
+
+	contactList, err := init.batchGetContactChunked(list)
+
+	init.BaseUserData.memberLock.Lock()
+	defer init.BaseUserData.memberLock.Unlock()
+	// 请求期间群组信息可能已更新，重新获取；群组成员列表可能正被读取，复制后再修改
+	group, ok = init.BaseUserData.GlobalMemberMap[groupName]
+	if !ok {
+		return err
+	}
+	groupMemberMap := make(map[string]User, len(group.GroupMemberMap)+len(contactList))
+	for userName, user := range group.GroupMemberMap {
+		groupMemberMap[userName] = user
+	}
+	for _, v := range contactList {
+		groupMemberMap[v.UserName] = User{
+			UserName:    v.UserName,
+			Uin:         v.Uin,
+			NickName:    v.NickName,
+			HeadImgUrl:  v.HeadImgUrl,
+			RemarkName:  v.RemarkName,
+			Sex:         v.Sex,
+			Signature:   v.Signature,
+			VerifyFlag:  v.VerifyFlag,
+			ContactFlag: v.ContactFlag,
+			Province:    v.Province,
+			City:        v.City,
+			Alias:       v.Alias,
+			DisplayName: v.DisplayName,
+		}
+	}
+	group.GroupMemberMap = groupMemberMap
+	init.BaseUserData.GlobalMemberMap[groupName] = group
+	return err
+}
+
+// 分批并发请求批量获取联系人接口，返回所有成功批次的联系人及第一个错误
+func (init *InitService) batchGetContactChunked(list []map[string]string) ([]Member, error) {
+	var (
+		wg          sync.WaitGroup
+		lock        sync.Mutex
+		firstErr    error
+		contactList []Member
+		sem         = make(chan struct{}, batchGetContactConcurrency)
+	)
+	for start := 0; start < len(list); start += batchGetContactSize {
+		end := start + batchGetContactSize
+		if end > len(list) {
+			end = len(list)
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []map[string]string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			members, err := init.batchGetContact(chunk)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			contactList = append(contactList, members...)
+		}(list[start:end])
+	}
+	wg.Wait()
+	return contactList, firstErr
+}
+
+// 请求批量获取联系人接口
+func (init *InitService) batchGetContact(list []map[string]string) ([]Member, error) {
 	params := url.Values{}
 	params.Set("type", "ex")
 	params.Set("lang", global.Common.Lang)
@@ -383,14 +539,7 @@ func (init *InitService) BatchGetContactInfo(ids []string) error {
 	params.Set("r", strconv.FormatInt(time.Now().Unix(), 10))
 	bodyParam := make(map[string]interface{})
 	bodyParam["BaseRequest"] = *init.LoginData.BaseRequest
-	bodyParam["Count"] = len(ids)
-	list := []map[string]string{}
-	for _, v := range ids {
-		list = append(list, map[string]string{
-			"UserName":   v,
-			"EncryChatRoomId": "",
-		})
-	}
+	bodyParam["Count"] = len(list)
 	bodyParam["List"] = list
 	bodyParamByte, _ := json.Marshal(bodyParam)
 
@@ -398,11 +547,11 @@ func (init *InitService) BatchGetContactInfo(ids []string) error {
 	resp, err := init.Request.Request(http.MethodPost, urlPath, bodyParamByte, util.JSON_HEADER)
 	if err != nil {
 		logrus.Warningf("批量获取联系人失败[err:%s]", err.Error())
-		return errors.InitLoginError.New().WithMsg("批量获取联系人失败").WithDesc(err.Error())
+		return nil, errors.InitLoginError.New().WithMsg("批量获取联系人失败").WithDesc(err.Error())
 	}
 
 	type contactBatch struct {
-		BaseResponse BaseRequest
+		BaseResponse BaseResponse
 		Count        int
 		ContactList  []Member
 	}
@@ -411,39 +560,57 @@ func (init *InitService) BatchGetContactInfo(ids []string) error {
 	err = json.Unmarshal(resp, respData)
 	if err != nil {
 		logrus.Warningf("批量获取联系人解析失败[err:%s]", err.Error())
-		return errors.InitLoginError.New().WithMsg("批量获取联系人解析失败").WithDesc(err.Error())
+		return nil, errors.InitLoginError.New().WithMsg("批量获取联系人解析失败").WithDesc(err.Error())
 	}
 
 	if respData.BaseResponse.Ret != 0 {
 		logrus.Warningf("批量获取联系人返回错误")
-		return errors.InitLoginError.New().WithMsg("批量获取联系人返回错误")
+		return nil, errors.InitLoginError.New().WithMsg("批量获取联系人返回错误")
 	}
+	return respData.ContactList, nil
+}
 
-	for _, v := range respData.ContactList {
-		if _, ok := init.BaseUserData.GlobalMemberMap[v.UserName]; ok {
-			temp := init.BaseUserData.GlobalMemberMap[v.UserName]
-			if v.UserName[:2] == "@@" {
-				if len(v.MemberList) > 0 {
-					groupMemberMap := make(map[string]User)
-					for _, v2 := range v.MemberList {
-						groupMemberMap[v2.UserName] = v2
-					}
-					temp.GroupMemberMap = groupMemberMap
-				}
-			}
-			temp.DisplayName = v.DisplayName
-			temp.NickName = v.NickName
-			temp.MemberCount = v.MemberCount
-			init.BaseUserData.GlobalMemberMap[v.UserName] = temp
+// 由联系人信息构建精简联系人信息
+func newTinyMemberInfo(item Member) TinyMemberInfo {
+	temp := TinyMemberInfo{
+		UserName:        item.UserName,
+		NickName:        item.NickName,
+		RemarkName:      item.RemarkName,
+		Alias:           item.Alias,
+		DisplayName:     item.DisplayName,
+		HeadImgUrl:      item.HeadImgUrl,
+		Sex:             item.Sex,
+		Signature:       item.Signature,
+		VerifyFlag:      item.VerifyFlag,
+		Province:        item.Province,
+		City:            item.City,
+		MemberCount:     item.MemberCount,
+		EncryChatRoomId: item.EncryChatRoomId,
+	}
+	if len(item.MemberList) > 0 {
+		groupMemberMap := make(map[string]User)
+		for _, v := range item.MemberList {
+			groupMemberMap[v.UserName] = v
 		}
+		temp.GroupMemberMap = groupMemberMap
 	}
-	return nil
+
+	if _, ok := global.Common.SpecialUsers[item.UserName]; ok {
+		temp.Type = types.CONTACT_TYPE_SPECIAL
+	} else if strings.HasPrefix(item.UserName, "@@") { // 群组
+		temp.Type = types.CONTACT_TYPE_GROUP
+	} else if strings.HasPrefix(item.UserName, "@") {
+		temp.Type = types.CONTACT_TYPE_MEMBER
+	} else {
+		temp.Type = types.CONTACT_TYPE_UNKONWN
+	}
+	return temp
 }
 
 
 func (init *InitService) SearchMemberInfo(userName, groupName string) (*User, *TinyMemberInfo) {
 	if groupName != "" {
-		if group, ok := init.BaseUserData.GlobalMemberMap[groupName]; ok {
+		if group, ok := init.BaseUserData.GetMember(groupName); ok {
 			if len(group.GroupMemberMap) > 0 {
 				if user, ok2 := group.GroupMemberMap[userName]; ok2 {
 					return &user, &group
@@ -451,9 +618,62 @@ func (init *InitService) SearchMemberInfo(userName, groupName string) (*User, *T
 			}
 		}
 	} else {
-		if user, ok := init.BaseUserData.GlobalMemberMap[userName]; ok {
+		if user, ok := init.BaseUserData.GetMember(userName); ok {
 			return nil, &user
 		}
 	}
 	return nil, nil
-}
\ No newline at end of file
+}
+
+// 按昵称或群昵称查找群组成员
+func (init *InitService) SearchMemberInfoByName(name, groupName string) (*User, *TinyMemberInfo) {
+	if group, ok := init.BaseUserData.GetMember(groupName); ok {
+		for userName, user := range group.GroupMemberMap {
+			if user.NickName == name || user.DisplayName == name {
+				return init.SearchMemberInfo(userName, groupName)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// 获取成员展示名称，优先级为群昵称、备注名、昵称
+// 群组成员不在缓存中时会尝试查询群组成员详情
+func (init *InitService) GetMemberName(userName, groupName string) string {
+	var user *User
+	if groupName != "" {
+		user, _ = init.SearchMemberInfo(userName, groupName)
+		missingKey := groupName + "/" + userName
+		init.missingLock.Lock()
+		missing := init.missingMembers[missingKey]
+		init.missingLock.Unlock()
+		if user == nil && !missing {
+			err := init.BatchGetGroupMemberInfo(groupName, []string{userName})
+			if err != nil {
+				logrus.Warningf("获取群组成员信息失败[group:%s, user:%s, err:%s]", groupName, userName, err.Error())
+			}
+			user, _ = init.SearchMemberInfo(userName, groupName)
+			// 请求失败时下次仍然重试
+			if user == nil && err == nil {
+				init.missingLock.Lock()
+				init.missingMembers[missingKey] = true
+				init.missingLock.Unlock()
+			}
+		}
+		if user != nil && user.DisplayName != "" {
+			return user.DisplayName
+		}
+	}
+
+	_, member := init.SearchMemberInfo(userName, "")
+	if member != nil && member.RemarkName != "" {
+		return member.RemarkName
+	}
+	if user != nil && user.NickName != "" {
+		return user.NickName
+	}
+	if member != nil && member.NickName != "" {
+		return member.NickName
+	}
+	return userName
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/sirupsen/logrus"
+)
+
+// 上传媒体文件类型
+const (
+	mediaTypePic   = "pic"
+	mediaTypeVideo = "video"
+	mediaTypeDoc   = "doc"
+)
+
+// 文件附件消息使用的appid
+const fileAppId = "wxeb7ec651dd0aedd5"
+
+var (
+	// 可以作为图片发送的文件类型
+	imageMimeTypes = map[string]bool{
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+		"image/bmp":  true,
+	}
+	// 可以作为视频发送的文件类型
+	videoMimeTypes = map[string]bool{
+		"video/mp4": true,
+	}
+)
+
+// 媒体消息
+type mediaMsg struct {
+	Type         int
+	MediaId      string
+	Content      string
+	FromUserName string
+	ToUserName   string
+	LocalID      string
+	ClientMsgId  string
+}
+
+// 发送图片，返回发送结果
+func (msg *MsgServices) SendImage(toUserName, filePath string) (SendResult, error) {
+	file, err := openMediaFile(filePath)
+	if err != nil {
+		return SendResult{}, err
+	}
+	defer file.Close()
+	return msg.SendImageReader(toUserName, filepath.Base(filePath), file)
+}
+
+// 从reader发送图片，返回发送结果
+func (msg *MsgServices) SendImageReader(toUserName, fileName string, r io.Reader) (SendResult, error) {
+	return msg.sendMedia(toUserName, fileName, r, mediaTypePic, 3, global.Common.WXUrlBase.WebWXSendMsgImgUrl)
+}
+
+// 发送视频，返回发送结果
+func (msg *MsgServices) SendVideo(toUserName, filePath string) (SendResult, error) {
+	file, err := openMediaFile(filePath)
+	if err != nil {
+		return SendResult{}, err
+	}
+	defer file.Close()
+	return msg.SendVideoReader(toUserName, filepath.Base(filePath), file)
+}
+
+// 从reader发送视频，返回发送结果
+func (msg *MsgServices) SendVideoReader(toUserName, fileName string, r io.Reader) (SendResult, error) {
+	return msg.sendMedia(toUserName, fileName, r, mediaTypeVideo, 43, global.Common.WXUrlBase.WebWXSendVideoMsgUrl)
+}
+
+// 发送文件，根据文件类型自动选择以图片、视频或文件附件发送，返回发送结果
+func (msg *MsgServices) SendFile(toUserName, filePath string) (SendResult, error) {
+	file, err := openMediaFile(filePath)
+	if err != nil {
+		return SendResult{}, err
+	}
+	defer file.Close()
+
+	var size int64 = -1
+	if fileInfo, err := file.Stat(); err == nil {
+		size = fileInfo.Size()
+	}
+	return msg.sendFile(toUserName, filepath.Base(filePath), file, size)
+}
+
+// 从reader发送文件，根据文件类型自动选择以图片、视频或文件附件发送，返回发送结果
+func (msg *MsgServices) SendFileReader(toUserName, fileName string, r io.Reader) (SendResult, error) {
+	return msg.sendFile(toUserName, fileName, r, -1)
+}
+
+// 发送文件，超过图片或视频大小限制的文件以附件发送，size为-1时先读取文件获取大小
+func (msg *MsgServices) sendFile(toUserName, fileName string, r io.Reader, size int64) (SendResult, error) {
+	mimeType := mime.TypeByExtension(filepath.Ext(fileName))
+	if mimeType == "" {
+		// 无法通过扩展名判断时读取文件头
+		header := make([]byte, 512)
+		n, _ := io.ReadFull(r, header)
+		mimeType = http.DetectContentType(header[:n])
+		r = io.MultiReader(bytes.NewReader(header[:n]), r)
+	}
+	if index := strings.Index(mimeType, ";"); index > 0 {
+		mimeType = mimeType[:index]
+	}
+
+	if size < 0 && (imageMimeTypes[mimeType] || videoMimeTypes[mimeType]) {
+		// reader不支持Seek时会写入临时文件，上传时不会重复读取原始reader
+		source, totalLen, _, cleanup, err := prepareUploadSource(r)
+		if err != nil {
+			logrus.Warningf("读取上传文件失败[file:%s, err:%s]", fileName, err.Error())
+			return SendResult{}, errors.UploadError.New().WithMsg("读取上传文件失败").WithDesc(err.Error())
+		}
+		defer cleanup()
+		r, size = source, totalLen
+	}
+
+	switch {
+	case imageMimeTypes[mimeType] && size <= mediaSizeLimits[mediaTypePic]:
+		return msg.SendImageReader(toUserName, fileName, r)
+	case videoMimeTypes[mimeType] && size <= mediaSizeLimits[mediaTypeVideo]:
+		return msg.SendVideoReader(toUserName, fileName, r)
+	}
+	return msg.SendAttachmentReader(toUserName, fileName, r)
+}
+
+// 以文件附件发送，返回发送结果
+func (msg *MsgServices) SendAttachmentReader(toUserName, fileName string, r io.Reader) (SendResult, error) {
+	toUserName, err := msg.InitService.ResolveRecipient(toUserName)
+	if err != nil {
+		return SendResult{}, err
+	}
+	mediaId, totalLen, err := msg.uploadMedia(toUserName, fileName, r, mediaTypeDoc)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("fun", "async")
+	params.Set("f", "json")
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	content := fmt.Sprintf("<appmsg appid='%s' sdkver=''><title>%s</title><des></des><action></action><type>6</type><content></content><url></url><lowurl></lowurl>"+
+		"<appattach><totallen>%d</totallen><attachid>%s</attachid><fileext>%s</fileext></appattach><extinfo></extinfo></appmsg>",
+		fileAppId, html.EscapeString(fileName), totalLen, mediaId, html.EscapeString(strings.TrimPrefix(filepath.Ext(fileName), ".")))
+
+	clientMsgId := newClientMsgId()
+	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.WebWXSendAppMsgUrl, params.Encode())
+	return msg.postSendMsg(urlPath, toUserName, clientMsgId, mediaMsg{
+		Type:         6,
+		Content:      content,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   toUserName,
+		LocalID:      clientMsgId,
+		ClientMsgId:  clientMsgId,
+	})
+}
+
+// 打开待发送的文件
+func openMediaFile(filePath string) (*os.File, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logrus.Warningf("打开文件失败[file:%s, err:%s]", filePath, err.Error())
+		return nil, errors.UploadError.New().WithMsg("打开文件失败").WithDesc(err.Error())
+	}
+	return file, nil
+}
+
+// 上传文件并发送对应类型的媒体消息，返回发送结果
+func (msg *MsgServices) sendMedia(toUserName, fileName string, r io.Reader, mediaType string, msgType int, sendUrl string) (SendResult, error) {
+	toUserName, err := msg.InitService.ResolveRecipient(toUserName)
+	if err != nil {
+		return SendResult{}, err
+	}
+	mediaId, _, err := msg.uploadMedia(toUserName, fileName, r, mediaType)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("fun", "async")
+	params.Set("f", "json")
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	clientMsgId := newClientMsgId()
+	urlPath := fmt.Sprintf("%s?%s", sendUrl, params.Encode())
+	return msg.postSendMsg(urlPath, toUserName, clientMsgId, mediaMsg{
+		Type:         msgType,
+		MediaId:      mediaId,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   toUserName,
+		LocalID:      clientMsgId,
+		ClientMsgId:  clientMsgId,
+	})
+}
+
+// 上传媒体文件，返回MediaId及文件大小
+func (msg *MsgServices) uploadMedia(toUserName, fileName string, r io.Reader, mediaType string) (string, int64, error) {
+	return msg.Uploader.Upload(UploadParam{
+		FileName:     fileName,
+		MediaType:    mediaType,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   toUserName,
+	}, r)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/util"
+)
+
+const (
+	// @成员名称后的分隔符，网页版消息中的四分之一空格已被统一为普通空格
+	mentionSep = " "
+	// 发送消息时@成员名称后使用四分之一空格，与客户端一致
+	mentionSendSep = "\u2005"
+)
+
+// 可被@的成员名称
+type mentionName struct {
+	name     string
+	userName string
+}
+
+// 解析群组消息中@的成员，返回成员id
+func (msg *MsgServices) parseMentions(groupName, content string) []string {
+	group, ok := msg.UserData.GetMember(groupName)
+	if !ok || len(group.GroupMemberMap) == 0 || !strings.Contains(content, "@") {
+		return nil
+	}
+
+	// 群昵称和昵称均可被@，按长度降序以优先匹配较长的名称
+	names := make([]mentionName, 0, len(group.GroupMemberMap)*2)
+	for userName, user := range group.GroupMemberMap {
+		for _, name := range []string{user.DisplayName, user.NickName} {
+			if name = util.NormalizeContent(name); name != "" {
+				names = append(names, mentionName{name: name, userName: userName})
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i].name) > len(names[j].name)
+	})
+
+	var (
+		userNames []string
+		exists    = make(map[string]bool)
+	)
+	for i := 0; i < len(content); {
+		index := strings.Index(content[i:], "@")
+		if index < 0 {
+			break
+		}
+		start := i + index + 1
+		i = start
+		for _, name := range names {
+			if !strings.HasPrefix(content[start:], name.name) {
+				continue
+			}
+			end := start + len(name.name)
+			if end != len(content) && !strings.HasPrefix(content[end:], mentionSep) {
+				continue
+			}
+			if !exists[name.userName] {
+				exists[name.userName] = true
+				userNames = append(userNames, name.userName)
+			}
+			i = end
+			break
+		}
+	}
+	return userNames
+}
+
+// 生成@群组成员的消息内容，格式为"@名称\u2005"，名称优先使用群昵称
+func (msg *MsgServices) BuildMentionContent(groupName, content string, userNames ...string) (string, error) {
+	groupName, err := msg.InitService.ResolveRecipient(groupName)
+	if err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for _, userName := range userNames {
+		user, _ := msg.InitService.SearchMemberInfo(userName, groupName)
+		if user == nil {
+			err := msg.InitService.BatchGetGroupMemberInfo(groupName, []string{userName})
+			if err != nil {
+				return "", err
+			}
+			user, _ = msg.InitService.SearchMemberInfo(userName, groupName)
+		}
+		if user == nil {
+			return "", errors.RecipientNotFoundError.New().WithDesc(fmt.Sprintf("群组成员不存在[group:%s, user:%s]", groupName, userName))
+		}
+		name := user.DisplayName
+		if name == "" {
+			name = user.NickName
+		}
+		builder.WriteString("@" + util.NormalizeContent(name) + mentionSendSep)
+	}
+	builder.WriteString(content)
+	return builder.String(), nil
+}
+
+// 发送@群组成员的消息
+func (msg *MsgServices) SendGroupMention(ctx context.Context, groupName, content string, userNames ...string) (SendResult, error) {
+	content, err := msg.BuildMentionContent(groupName, content, userNames...)
+	if err != nil {
+		return SendResult{}, err
+	}
+	return msg.Send(ctx, SendMessage{
+		ToUserName: groupName,
+		Content:    content,
+	})
+}
@@ -2,16 +2,19 @@ package services
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"math/rand"
+	"html"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/constants/types"
 	"github.com/oliverCJ/go-wechat/global"
 	"github.com/oliverCJ/go-wechat/util"
 	"github.com/sirupsen/logrus"
@@ -23,37 +26,97 @@ type MsgServices struct {
 	Request   *util.Request
 	// 检查消息单独实例
 	CheckRequest *util.Request
+	// 上传文件
+	Uploader *MediaUploader
 
 	InitService *InitService
 
 	msgResp *SyncMsgResp
-	// 是否自动回复
-	autoReply bool
+	// 消息服务配置
+	option MsgOption
 	// 消息读取通道
 	MsgRead chan Message
 	// 消息发送通道
 	MsgSend chan SendMessage
 	// 消息发送响应
 	MsgSendResp chan SendMessageResp
+	// 消息发送队列
+	SendQueue *SendQueue
+	// 定时消息
+	Scheduler *Scheduler
+	// 最近消息缓存，用于查找被撤回的消息
+	msgCache *msgCache
+	// 最近发送的消息，用于判断是否超过撤回时限
+	sentRecords *sentRecords
+	// 本次拉取的消息中待标记已读的会话
+	readChats map[string]bool
+	// 同步发送等待结果，key为LocalID
+	sendWaiters map[string]chan sendOutcome
+	sendLock    sync.Mutex
+	// 发送频率控制
+	sendLimiter sendLimiter
+	// 待投递到MsgSendResp的发送响应
+	sendResps      []SendMessageResp
+	sendRespLock   sync.Mutex
+	sendRespNotify chan struct{}
+}
+
+// 消息服务配置
+type MsgOption struct {
+	// 是否自动回复
+	AutoReply bool
+	// 是否忽略当前登录用户在其他设备发送的消息
+	IgnoreSelfMsg bool
+	// 上传文件进度回调
+	UploadProgress UploadProgressFunc
+	// 上传文件单个分块超时时间，为0时使用默认值
+	UploadTimeout time.Duration
+	// 是否在消息投递后自动将会话标记为已读
+	AutoMarkRead bool
+	// 项目目录，用于保存发送队列
+	RootDir string
+	// 两次发送之间的间隔，为0时使用默认值
+	SendInterval time.Duration
+	// 单条消息最大发送次数，超过后移入死信列表，为0时使用默认值
+	SendMaxAttempts int
 }
 
-func NewMsgService(initService *InitService, autoReply bool, msgRead chan Message, msgSend chan SendMessage, msgSendResp chan SendMessageResp) *MsgServices {
+func NewMsgService(initService *InitService, option MsgOption, msgRead chan Message, msgSend chan SendMessage, msgSendResp chan SendMessageResp) *MsgServices {
 	// 检查消息需要设置cookie
 	u, _ := url.Parse(global.HostWx)
 	cookieRequest := util.NewRequest()
 	cookieRequest.Client.Jar.SetCookies(u, initService.LoginData.Cookie)
+	uploader := NewMediaUploader(initService.LoginData)
+	uploader.Progress = option.UploadProgress
+	if option.UploadTimeout > 0 {
+		uploader.Request.SetTimeout(option.UploadTimeout)
+	}
+	if option.SendInterval <= 0 {
+		option.SendInterval = defaultSendInterval
+	}
+	if option.SendMaxAttempts <= 0 {
+		option.SendMaxAttempts = defaultSendMaxAttempts
+	}
 
 	return &MsgServices{
-		LoginData:    initService.LoginData,
-		UserData:     initService.BaseUserData,
-		Request:      util.NewRequest(),
-		CheckRequest: cookieRequest,
-		InitService:  initService,
-		MsgRead:      msgRead,
-		MsgSend:      msgSend,
-		MsgSendResp:  msgSendResp,
-		msgResp:      &SyncMsgResp{},
-		autoReply:    autoReply,
+		LoginData:      initService.LoginData,
+		UserData:       initService.BaseUserData,
+		Request:        util.NewRequest(),
+		CheckRequest:   cookieRequest,
+		Uploader:       uploader,
+		InitService:    initService,
+		MsgRead:        msgRead,
+		MsgSend:        msgSend,
+		MsgSendResp:    msgSendResp,
+		SendQueue:      NewSendQueue(option.RootDir),
+		Scheduler:      NewScheduler(option.RootDir),
+		msgResp:        &SyncMsgResp{},
+		option:         option,
+		msgCache:       newMsgCache(defaultMsgCacheSize),
+		sentRecords:    newSentRecords(),
+		readChats:      make(map[string]bool),
+		sendWaiters:    make(map[string]chan sendOutcome),
+		sendRespNotify: make(chan struct{}, 1),
 	}
 }
 
@@ -187,74 +250,157 @@ func (msg *MsgServices) ParseMsg() error {
 	if len(msg.msgResp.AddMsgList) > 0 {
 		for _, v := range msg.msgResp.AddMsgList {
 			logrus.Debugf("收到消息:%+v", v)
+			raw, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
 			message := Message{}
-			msgType := v.(map[string]interface{})["MsgType"].(float64)
-			message.MsgType = int(msgType)
-			message.FromUserName = v.(map[string]interface{})["FromUserName"].(string)
-			message.ToUserName = v.(map[string]interface{})["ToUserName"].(string)
+			fillRawFields(&message, raw)
 			message.RealUserName = message.FromUserName
-			if nickName, ok := msg.UserData.GlobalMemberMap[message.FromUserName]; ok {
+			// 当前登录用户在其他设备发送的消息，会话对象为接收者
+			message.IsSelf = message.FromUserName == msg.UserData.UserInfo.UserName
+			message.ChatUserName = message.FromUserName
+			if message.IsSelf {
+				if msg.option.IgnoreSelfMsg {
+					continue
+				}
+				message.ChatUserName = message.ToUserName
+			}
+			if nickName, ok := msg.UserData.GetMember(message.FromUserName); ok {
 				message.FromUserNickName = nickName.NickName
 			}
-			if nickName, ok := msg.UserData.GlobalMemberMap[message.ToUserName]; ok {
+			if nickName, ok := msg.UserData.GetMember(message.ToUserName); ok {
 				message.ToUserNickName = nickName.NickName
 			}
 			message.RealUserNickName = message.FromUserNickName
 
-			message.Content = v.(map[string]interface{})["Content"].(string)
-			message.FormatContent = strings.Replace(message.Content, "&lt;", "<", -1)
-			message.FormatContent = strings.Replace(message.Content, "&gt;", ">", -1)
-			message.FormatContent = strings.Replace(message.Content, " ", " ", 1)
+			if strings.HasPrefix(message.FromUserName, "@@") {
+				// 其他成员的群组消息需要去除发送者前缀
+				message.FormatContent = util.NormalizeContent(stripGroupSender(message.Content))
+			} else {
+				message.FormatContent = util.NormalizeContent(message.Content)
+			}
 
-			if message.ToUserName == "filehelper" {
+			if message.IsSelf || message.ToUserName == "filehelper" {
 				message.FromUserNickName = msg.UserData.UserInfo.NickName
+				message.RealUserNickName = message.FromUserNickName
 			}
 
 			// 群组消息发送者需要单独获取
-			if message.FromUserName[:2] == "@@" {
+			if strings.HasPrefix(message.FromUserName, "@@") {
 				groupMemberMatches := regexp.MustCompile(`@(\S+):`)
 				matchResult := groupMemberMatches.FindStringSubmatch(message.Content)
 				if len(matchResult) == 2 {
 					message.RealUserName = "@" + matchResult[1]
+					// 发送者不在缓存中时会查询群组成员详情
+					message.RealUserDisplayName = msg.InitService.GetMemberName(message.RealUserName, message.FromUserName)
 					user, _ := msg.InitService.SearchMemberInfo(message.RealUserName, message.FromUserName)
 					if user != nil {
 						message.RealUserNickName = user.NickName
 					}
-					if group, ok := msg.UserData.GlobalMemberMap[message.FromUserName]; ok {
-						message.FromGroupNickName = group.DisplayName
+					if group, ok := msg.UserData.GetMember(message.FromUserName); ok {
+						message.FromGroupNickName = group.NickName
 					}
 				}
+			} else {
+				if message.IsSelf && strings.HasPrefix(message.ChatUserName, "@@") {
+					message.RealUserDisplayName = msg.InitService.GetMemberName(message.RealUserName, message.ChatUserName)
+					if group, ok := msg.UserData.GetMember(message.ChatUserName); ok {
+						message.FromGroupNickName = group.NickName
+					}
+				} else if message.IsSelf {
+					message.RealUserDisplayName = message.RealUserNickName
+				} else {
+					message.RealUserDisplayName = msg.InitService.GetMemberName(message.RealUserName, "")
+				}
+			}
+
+			// 自定义解析器优先，解析失败时使用内置解析，避免丢失消息
+			if decoder, ok := getMsgDecoder(message.MsgType, message.AppMsgType); ok {
+				decoded := message
+				err := decoder(&decoded)
+				if err == nil {
+					msg.emit(decoded)
+					continue
+				}
+				logrus.Warningf("自定义解析消息失败,使用内置解析[msgId:%d, msgType:%d, appMsgType:%d, err:%s]", message.MsgId, message.MsgType, message.AppMsgType, err.Error())
 			}
 
 			switch message.MsgType {
 			case 1: // 文本消息
-				// 群组消息
-				if message.FromUserName[:2] == "@@" {
-					contentSlice := strings.Split(message.FormatContent, ":<br/>")
-					message.FormatContent = contentSlice[1]
+				if strings.HasPrefix(message.ChatUserName, "@@") {
+					// 群组消息中@的成员
+					message.MentionedUserNames = msg.parseMentions(message.ChatUserName, message.FormatContent)
+					for _, userName := range message.MentionedUserNames {
+						if userName == msg.UserData.UserInfo.UserName {
+							message.IsMentioningMe = true
+							break
+						}
+					}
 				} else {
-					if msg.autoReply {
+					if msg.option.AutoReply {
 						//TODO
 					}
 				}
-				msg.MsgRead <- message
-			case 3, 47: // 图片
-				message.FormatContent = "[收到图片表情,请在手机上查看]"
-				msg.MsgRead <- message
+				msg.emit(message)
+			case 3: // 图片
+				message.FormatContent = "[收到图片,请在手机上查看]"
+				msg.emit(message)
+			case 47: // 表情
+				emoticonInfo, err := parseEmoticonMsg(message.Content, rawInt(raw, "HasProductId") != 0)
+				if err != nil {
+					// 解析失败时仍然投递消息
+					logrus.Warningf("解析表情消息失败[content:%s, err:%s]", message.Content, err.Error())
+					message.FormatContent = "[收到图片表情,请在手机上查看]"
+				} else {
+					message.EmoticonInfo = emoticonInfo
+					message.FormatContent = "[表情]"
+				}
+				msg.emit(message)
 			case 34: // 语音
 				message.FormatContent = "[收到语音消息,请在手机上查看]"
-				msg.MsgRead <- message
+				msg.emit(message)
 			case 37: // 好友请求
-				message.FormatContent = "[收到好友请求,请在手机上查看]"
-				msg.MsgRead <- message
+				message.FormatContent = fmt.Sprintf("[收到%s的好友请求:%s]", message.RecommendInfo.NickName, message.RecommendInfo.Content)
+				msg.emit(message)
 			case 42: // 分享名片
+				cardInfo, err := parseCardMsg(message.Content)
+				if err != nil {
+					// 解析失败时仍然投递消息
+					logrus.Warningf("解析名片消息失败[content:%s, err:%s]", message.Content, err.Error())
+					message.FormatContent = "[收到名片消息,请在手机上查看]"
+				} else {
+					message.CardInfo = cardInfo
+					message.FormatContent = fmt.Sprintf("[名片:%s]", cardInfo.NickName)
+				}
+				msg.emit(message)
 			case 43: // 小视频
 				message.FormatContent = "[收到视频消息,请在手机上查看]"
-				msg.MsgRead <- message
+				msg.emit(message)
 			case 48: // 定位消息
-				message.FormatContent = "[收到定位消息,请在手机上查看]"
-				msg.MsgRead <- message
-			case 49: // 多媒体消息
+				locationInfo, err := parseLocationMsg(message.Content, rawString(raw, "OriContent"), message.Url)
+				if err != nil {
+					// 解析失败时仍然投递消息
+					logrus.Warningf("解析定位消息失败[content:%s, err:%s]", message.Content, err.Error())
+					message.FormatContent = "[收到定位消息,请在手机上查看]"
+				} else {
+					message.LocationInfo = locationInfo
+					message.FormatContent = fmt.Sprintf("[位置:%s]", locationInfo.Label)
+				}
+				msg.emit(message)
+			case 49: // 多媒体消息，保留原始Content用于转发
+				fileName := util.NormalizeContent(message.FileName)
+				switch message.AppMsgType {
+				case 5: // 链接
+					message.FormatContent = fmt.Sprintf("[链接:%s]", fileName)
+				case 6: // 文件
+					message.FormatContent = fmt.Sprintf("[文件:%s]", fileName)
+				case 33, 36: // 小程序
+					message.FormatContent = fmt.Sprintf("[小程序:%s]", fileName)
+				default:
+					message.FormatContent = "[收到应用消息,请在手机上查看]"
+				}
+				msg.emit(message)
 			case 50:
 			case 51: // 状态通知，访问了某一个聊天页面
 			case 52:
@@ -262,81 +408,195 @@ func (msg *MsgServices) ParseMsg() error {
 			case 62: // 短视频
 			case 9999: //系统通知
 			case 10000: // 系统消息
+				if msg.parseSysNotice(&message) {
+					msg.emit(message)
+				}
 			case 10002: // 撤回消息
-			default: // 未知消息
-				msg.MsgRead <- Message{
-					FormatContent: fmt.Sprintf("未知消息:%s", v),
+				message.Event = types.MSG_EVENT_REVOKED
+				revokeInfo, err := msg.parseRevokeMsg(message.Content)
+				if err != nil {
+					// 解析失败时仍然投递撤回事件，原始内容保留在Content中
+					logrus.Warningf("解析撤回消息失败[content:%s, err:%s]", message.Content, err.Error())
+					message.FormatContent = "[有消息被撤回]"
+				} else {
+					message.RevokeInfo = revokeInfo
+					message.FormatContent = revokeInfo.ReplaceMsg
 				}
+				msg.MsgRead <- message
+			default: // 未知消息，保留原始数据
+				message.FormatContent = fmt.Sprintf("未知消息:%s", message.FormatContent)
+				msg.emit(message)
 			}
 		}
 	}
+	if len(msg.readChats) > 0 {
+		chats := make([]string, 0, len(msg.readChats))
+		for chat := range msg.readChats {
+			chats = append(chats, chat)
+		}
+		msg.readChats = make(map[string]bool)
+		go msg.markChatsRead(chats)
+	}
 	return nil
 }
 
-func (msg *MsgServices) sendMsg(message SendMessage) error {
+// 缓存并投递消息，开启自动已读时记录需要标记已读的会话
+func (msg *MsgServices) emit(message Message) {
+	msg.msgCache.Put(message)
+	msg.MsgRead <- message
+	if msg.option.AutoMarkRead && !message.IsSelf && message.ChatUserName != "" {
+		msg.readChats[message.ChatUserName] = true
+	}
+}
+
+// 将会话标记为已读
+func (msg *MsgServices) MarkRead(userName string) error {
+	userName, err := msg.InitService.ResolveRecipient(userName)
+	if err != nil {
+		return err
+	}
+	err = msg.InitService.notifyStatus(statusNotifyCodeRead, userName)
+	if err != nil {
+		logrus.Warningf("标记已读失败[user:%s, err:%s]", userName, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (msg *MsgServices) markChatsRead(chats []string) {
+	for _, chat := range chats {
+		_ = msg.MarkRead(chat)
+	}
+}
+
+// 解析撤回消息，并从缓存中查找原始消息
+func (msg *MsgServices) parseRevokeMsg(content string) (*RevokeInfo, error) {
+	content = html.UnescapeString(content)
+	// 群组消息会带有发送者前缀
+	if index := strings.Index(content, "<sysmsg"); index > 0 {
+		content = content[index:]
+	}
+
+	revokeXml := new(RevokeMsgXml)
+	err := xml.Unmarshal([]byte(content), revokeXml)
+	if err != nil {
+		return nil, errors.MsgError.New().WithMsg("解析撤回消息失败").WithDesc(err.Error())
+	}
+
+	msgId, err := strconv.ParseInt(revokeXml.RevokeMsg.MsgId, 10, 64)
+	if err != nil {
+		return nil, errors.MsgError.New().WithMsg("解析撤回消息id失败").WithDesc(err.Error())
+	}
+
+	revokeInfo := &RevokeInfo{
+		MsgId:      msgId,
+		ReplaceMsg: revokeXml.RevokeMsg.ReplaceMsg,
+	}
+	if origin, ok := msg.msgCache.Get(msgId); ok {
+		revokeInfo.OriginMsg = &origin
+	}
+	return revokeInfo, nil
+}
+
+func (msg *MsgServices) sendMsg(message SendMessage) (SendResult, error) {
 	params := url.Values{}
 	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
 
-	rand.Seed(time.Now().Unix())
-	randMsgId := strconv.FormatInt(time.Now().Unix()<<4, 10) + strconv.Itoa(rand.Int())[0:4]
-
-	reqBodyParam, _ := json.Marshal(struct {
-		BaseRequest *BaseRequest
-		Msg         struct {
-			Type         int
-			Content      string
-			FromUserName string
-			ToUserName   string
-			LocalID      string
-			ClientMsgId  string
-			MediaId      string
-		}
-		Scene int
-	}{
-		BaseRequest: msg.LoginData.BaseRequest,
-		Msg: struct {
-			Type         int
-			Content      string
-			FromUserName string
-			ToUserName   string
-			LocalID      string
-			ClientMsgId  string
-			MediaId      string
-		}{
-			Type:         1,
-			Content:      message.Content,
-			FromUserName: msg.UserData.UserInfo.UserName,
-			ToUserName:   message.ToUserName,
-			LocalID:      message.LocalID,
-			ClientMsgId:  randMsgId,
-			MediaId:      "",
-		},
-	})
+	if message.LocalID == "" {
+		message.LocalID = newClientMsgId()
+	}
+	toUserName, err := msg.InitService.ResolveRecipient(message.ToUserName)
+	if err != nil {
+		return SendResult{}, err
+	}
+	message.ToUserName = toUserName
 
 	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.WebWXSendMsgUrl, params.Encode())
-	resp, err := msg.Request.Request(http.MethodPost, urlPath, reqBodyParam, util.JSON_HEADER)
+	result, err := msg.postSendMsg(urlPath, message.ToUserName, message.LocalID, mediaMsg{
+		Type:         1,
+		Content:      message.Content,
+		FromUserName: msg.UserData.UserInfo.UserName,
+		ToUserName:   message.ToUserName,
+		LocalID:      message.LocalID,
+		ClientMsgId:  message.LocalID,
+	})
 	if err != nil {
-		logrus.Warningf("消息发送失败[msg:%+v, err:%s]", message, err.Error())
-		return errors.MsgError.New().WithMsg("消息发送失败").WithDesc(fmt.Sprintf("[msg:%+v, err:%s]", message, err.Error()))
+		return SendResult{}, err
 	}
 
-	respData := SendMessageResp{}
-	_ = json.Unmarshal(resp, respData)
-	msg.MsgSendResp <- respData
+	// 兼容通过发送通道发送的消息，由单独的协程投递，避免阻塞发送
+	msg.pushSendResp(SendMessageResp{MsgID: result.MsgID, LocalID: result.LocalID})
 
-	return nil
+	return result, nil
 }
 
 func (msg *MsgServices) SendMsgDaemon(close chan<- bool) {
+	go msg.sendQueueDaemon()
+	go msg.sendRespDaemon()
+
 	for {
 		select {
 		case m := <-msg.MsgSend:
-			err := msg.sendMsg(m)
-			if err != nil {
-				logrus.Warningf("消息发送失败[err:%s]", err.Error())
+			if m.LocalID == "" {
+				m.LocalID = newClientMsgId()
 			}
+			msg.SendQueue.Push(m)
+		}
+	}
+}
+
+// 依次发送队列中的消息，失败时按退避间隔重试，无法重试或超过最大次数后移入死信列表
+func (msg *MsgServices) sendQueueDaemon() {
+	for {
+		item, wait := msg.SendQueue.next()
+		if item == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-msg.SendQueue.notify:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		// 控制发送频率
+		msg.sendLimiter.wait(msg.option.SendInterval)
+		result, err := msg.sendMsg(item.Message)
+		if err == nil {
+			msg.SendQueue.done(item)
+			msg.notifySendResult(item.Message.LocalID, result, nil)
+		} else if delay, ok := msg.sendRetryDelay(item, err); ok {
+			logrus.Warningf("消息发送失败,%s后重试[localId:%s, attempts:%d, err:%s]", delay, item.Message.LocalID, item.Attempts+1, err.Error())
+			msg.SendQueue.retry(item, err, delay)
+		} else {
+			logrus.Warningf("消息发送失败,移入死信列表[localId:%s, attempts:%d, err:%s]", item.Message.LocalID, item.Attempts+1, err.Error())
+			msg.SendQueue.dead(item, err)
+			msg.notifySendResult(item.Message.LocalID, SendResult{}, err)
+		}
+	}
+}
+
+// 判断发送失败的消息是否需要重试及重试间隔
+// 网络错误和频率限制在最大次数内重试，登录失效时一直保留等待重新登录，接收者错误及其他接口错误不重试
+func (msg *MsgServices) sendRetryDelay(item *QueuedMessage, err error) (time.Duration, bool) {
+	delay := sendRetryDelay(item.Attempts)
+	e, ok := err.(*errors.WeChatError)
+	switch {
+	case ok && e.Is(errors.LoginExpiredError):
+		return sendRetryMaxInterval, true
+	case ok && (e.Is(errors.RecipientNotFoundError) || e.Is(errors.RecipientAmbiguousError)):
+		return 0, false
+	case item.Attempts+1 >= msg.option.SendMaxAttempts:
+		return 0, false
+	case ok && e.Is(errors.FrequencyLimitError):
+		if delay < sendFrequencyLimitInterval {
+			delay = sendFrequencyLimitInterval
 		}
+		return delay, true
+	case ok && e.Code != 0:
+		return 0, false
 	}
+	return delay, true
 }
 
 func (msg *MsgServices) SyncDaemon(close chan<- bool) {
@@ -0,0 +1,58 @@
+package services
+
+import "sync"
+
+// 默认缓存最近消息数量
+const defaultMsgCacheSize = 1000
+
+// 最近消息缓存，超过容量后淘汰最早的消息
+type msgCache struct {
+	mu   sync.Mutex
+	size int
+	// 按写入顺序记录的消息id，用作环形队列
+	keys []int64
+	next int
+	data map[int64]Message
+}
+
+func newMsgCache(size int) *msgCache {
+	if size <= 0 {
+		size = defaultMsgCacheSize
+	}
+	return &msgCache{
+		size: size,
+		keys: make([]int64, 0, size),
+		data: make(map[int64]Message, size),
+	}
+}
+
+// 缓存消息
+func (c *msgCache) Put(message Message) {
+	if message.MsgId == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.data[message.MsgId]; ok {
+		c.data[message.MsgId] = message
+		return
+	}
+	if len(c.keys) < c.size {
+		c.keys = append(c.keys, message.MsgId)
+	} else {
+		delete(c.data, c.keys[c.next])
+		c.keys[c.next] = message.MsgId
+		c.next = (c.next + 1) % c.size
+	}
+	c.data[message.MsgId] = message
+}
+
+// 查找消息
+func (c *msgCache) Get(msgId int64) (Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	message, ok := c.data[msgId]
+	return message, ok
+}
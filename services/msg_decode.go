@@ -0,0 +1,211 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+)
+
+// 自定义消息解析器，返回错误时丢弃解析器的修改，改用内置解析投递消息
+type MsgDecoder func(message *Message) error
+
+// 自定义消息解析器索引
+type msgDecoderKey struct {
+	msgType    int
+	appMsgType int
+}
+
+var msgDecoders = struct {
+	sync.RWMutex
+	decoders map[msgDecoderKey]MsgDecoder
+}{
+	decoders: make(map[msgDecoderKey]MsgDecoder),
+}
+
+/**
+ *  注册自定义消息解析器，优先于内置解析
+ *  msgType：消息类型
+ *  appMsgType：多媒体消息类型，为0时匹配该消息类型的所有消息
+ */
+func RegisterMsgDecoder(msgType, appMsgType int, decoder MsgDecoder) {
+	msgDecoders.Lock()
+	defer msgDecoders.Unlock()
+
+	key := msgDecoderKey{msgType: msgType, appMsgType: appMsgType}
+	if decoder == nil {
+		delete(msgDecoders.decoders, key)
+		return
+	}
+	msgDecoders.decoders[key] = decoder
+}
+
+// 查找自定义消息解析器
+func getMsgDecoder(msgType, appMsgType int) (MsgDecoder, bool) {
+	msgDecoders.RLock()
+	defer msgDecoders.RUnlock()
+
+	if decoder, ok := msgDecoders.decoders[msgDecoderKey{msgType: msgType, appMsgType: appMsgType}]; ok {
+		return decoder, true
+	}
+	decoder, ok := msgDecoders.decoders[msgDecoderKey{msgType: msgType}]
+	return decoder, ok
+}
+
+// 从原始消息中读取字符串
+func rawString(raw map[string]interface{}, key string) string {
+	value, _ := raw[key].(string)
+	return value
+}
+
+// 从原始消息中读取数字
+func rawInt(raw map[string]interface{}, key string) int64 {
+	switch value := raw[key].(type) {
+	case float64:
+		return int64(value)
+	case string:
+		number, _ := strconv.ParseInt(value, 10, 64)
+		return number
+	}
+	return 0
+}
+
+// 由原始消息填充消息字段
+func fillRawFields(message *Message, raw map[string]interface{}) {
+	message.Raw = raw
+	message.MsgId = rawInt(raw, "MsgId")
+	message.FromUserName = rawString(raw, "FromUserName")
+	message.ToUserName = rawString(raw, "ToUserName")
+	message.MsgType = int(rawInt(raw, "MsgType"))
+	message.PlayLength = int(rawInt(raw, "PlayLength"))
+	message.Content = rawString(raw, "Content")
+	message.StatusNotifyUserName = rawString(raw, "StatusNotifyUserName")
+	message.StatusNotifyCode = int(rawInt(raw, "StatusNotifyCode"))
+	message.Status = int(rawInt(raw, "Status"))
+	message.VoiceLength = int(rawInt(raw, "VoiceLength"))
+	message.ForwardFlag = int(rawInt(raw, "ForwardFlag"))
+	message.AppMsgType = int(rawInt(raw, "AppMsgType"))
+	message.Url = rawString(raw, "Url")
+	message.ImgStatus = int(rawInt(raw, "ImgStatus"))
+	message.ImgWidth = int(rawInt(raw, "ImgWidth"))
+	message.ImgHeight = int(rawInt(raw, "ImgHeight"))
+	message.MediaId = rawString(raw, "MediaId")
+	message.FileName = rawString(raw, "FileName")
+	message.FileSize = rawString(raw, "FileSize")
+	message.CreateTime = int32(rawInt(raw, "CreateTime"))
+
+	if appInfo, ok := raw["AppInfo"].(map[string]interface{}); ok {
+		message.AppInfo.AppID = rawString(appInfo, "AppID")
+		message.AppInfo.Type = int(rawInt(appInfo, "Type"))
+	}
+	if recommendInfo, ok := raw["RecommendInfo"]; ok {
+		recommendInfoByte, _ := json.Marshal(recommendInfo)
+		_ = json.Unmarshal(recommendInfoByte, &message.RecommendInfo)
+	}
+}
+
+// 群组消息发送者前缀分隔符
+const groupSenderSep = ":<br/>"
+
+// 地图链接中的坐标
+var locationCoordRegexp = regexp.MustCompile(`coord=(-?[\d.]+),(-?[\d.]+)`)
+
+// 去除群组消息的发送者前缀
+func stripGroupSender(content string) string {
+	if strings.HasPrefix(content, "@") {
+		if index := strings.Index(content, groupSenderSep); index > 0 {
+			return content[index+len(groupSenderSep):]
+		}
+	}
+	return content
+}
+
+// 还原消息中转义的xml内容
+func unescapeXml(content string) string {
+	content = strings.Replace(stripGroupSender(content), "<br/>", "\n", -1)
+	return strings.TrimSpace(html.UnescapeString(content))
+}
+
+// 解析消息中的xml内容，消息中的xml并不严格，需要使用非严格模式
+func decodeXml(content string, v interface{}) error {
+	decoder := xml.NewDecoder(strings.NewReader(unescapeXml(content)))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	return decoder.Decode(v)
+}
+
+// 解析名片消息
+func parseCardMsg(content string) (*CardInfo, error) {
+	cardXml := new(CardMsgXml)
+	err := decodeXml(content, cardXml)
+	if err != nil {
+		return nil, errors.MsgError.New().WithMsg("解析名片消息失败").WithDesc(err.Error())
+	}
+	return &CardInfo{
+		UserName: cardXml.UserName,
+		NickName: cardXml.NickName,
+		Alias:    cardXml.Alias,
+		Province: cardXml.Province,
+		City:     cardXml.City,
+		Sex:      cardXml.Sex,
+	}, nil
+}
+
+// 解析定位消息，优先使用OriContent中的坐标，没有时从地图链接和消息内容中获取
+func parseLocationMsg(content, oriContent, mapUrl string) (*LocationInfo, error) {
+	locationInfo := &LocationInfo{
+		Url: mapUrl,
+	}
+
+	if oriContent != "" {
+		locationXml := new(LocationMsgXml)
+		err := decodeXml(oriContent, locationXml)
+		if err == nil {
+			locationInfo.Latitude = locationXml.Location.X
+			locationInfo.Longitude = locationXml.Location.Y
+			locationInfo.Label = locationXml.Location.Label
+			locationInfo.PoiName = locationXml.Location.PoiName
+			return locationInfo, nil
+		}
+	}
+
+	matchResult := locationCoordRegexp.FindStringSubmatch(mapUrl)
+	if len(matchResult) != 3 {
+		return nil, errors.MsgError.New().WithMsg("解析定位消息失败").WithDesc("没有找到坐标信息")
+	}
+	locationInfo.Latitude, _ = strconv.ParseFloat(matchResult[1], 64)
+	locationInfo.Longitude, _ = strconv.ParseFloat(matchResult[2], 64)
+	// 消息内容格式为 地址:<br/>位置图片链接
+	contentSlice := strings.SplitN(stripGroupSender(content), groupSenderSep, 2)
+	locationInfo.Label = html.UnescapeString(contentSlice[0])
+	locationInfo.PoiName = locationInfo.Label
+	return locationInfo, nil
+}
+
+// 解析表情消息，商店表情的消息内容可能为空
+func parseEmoticonMsg(content string, hasProductId bool) (*EmoticonInfo, error) {
+	emoticonInfo := &EmoticonInfo{
+		IsCustom: !hasProductId,
+	}
+	if strings.TrimSpace(content) == "" {
+		return emoticonInfo, nil
+	}
+
+	emojiXml := new(EmojiMsgXml)
+	err := decodeXml(content, emojiXml)
+	if err != nil {
+		return nil, errors.MsgError.New().WithMsg("解析表情消息失败").WithDesc(err.Error())
+	}
+	emoticonInfo.Md5 = emojiXml.Emoji.Md5
+	emoticonInfo.CdnUrl = emojiXml.Emoji.CdnUrl
+	emoticonInfo.Width = emojiXml.Emoji.Width
+	emoticonInfo.Height = emojiXml.Emoji.Height
+	emoticonInfo.ProductId = emojiXml.Emoji.ProductId
+	emoticonInfo.IsCustom = !hasProductId && emojiXml.Emoji.ProductId == ""
+	return emoticonInfo, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCardMsg(t *testing.T) {
+	cases := []struct {
+		content string
+		want    *CardInfo
+	}{
+		{
+			`&lt;?xml version="1.0"?&gt;<br/>&lt;msg bigheadimgurl="" smallheadimgurl="" username="@abc" nickname="张三" fullpy="zhangsan" shortpy="" alias="zs123" imagestatus="3" scene="17" province="广东" city="深圳" sign="" sex="1" certflag="0" certinfo="" brandIconUrl="" brandHomeUrl="" brandSubscriptConfigUrl="" brandFlags="0" regionCode="CN_Guangdong_Shenzhen" /&gt;<br/>`,
+			&CardInfo{UserName: "@abc", NickName: "张三", Alias: "zs123", Province: "广东", City: "深圳", Sex: 1},
+		},
+		{
+			// 群组中的名片带有发送者前缀
+			`@123:<br/>&lt;?xml version="1.0"?&gt;<br/>&lt;msg username="@def" nickname="李四 &amp;amp; 王五" sex="2" /&gt;<br/>`,
+			&CardInfo{UserName: "@def", NickName: "李四 & 王五", Sex: 2},
+		},
+	}
+	for _, c := range cases {
+		got, err := parseCardMsg(c.content)
+		if err != nil {
+			t.Errorf("parseCardMsg(%q) error: %s", c.content, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseCardMsg(%q) = %+v, want %+v", c.content, got, c.want)
+		}
+	}
+
+	if _, err := parseCardMsg("不是名片"); err == nil {
+		t.Errorf("parseCardMsg expected error")
+	}
+}
+
+func TestParseLocationMsg(t *testing.T) {
+	mapUrl := "http://apis.map.qq.com/uri/v1/geocoder?coord=22.543099,114.057868"
+	cases := []struct {
+		content    string
+		oriContent string
+		want       *LocationInfo
+	}{
+		{
+			"深圳市福田区福华路:<br/>/cgi-bin/mmwebwx-bin/webwxgetpubliclinkimg?url=xxx",
+			`&lt;?xml version="1.0"?&gt;<br/>&lt;msg&gt;<br/>	&lt;location x="22.543099" y="114.057868" scale="16" label="深圳市福田区福华路" maptype="0" poiname="市民中心" /&gt;<br/>&lt;/msg&gt;<br/>`,
+			&LocationInfo{Latitude: 22.543099, Longitude: 114.057868, Label: "深圳市福田区福华路", PoiName: "市民中心", Url: mapUrl},
+		},
+		{
+			// 没有OriContent时从地图链接获取坐标
+			"@123:<br/>深圳市福田区福华路:<br/>/cgi-bin/mmwebwx-bin/webwxgetpubliclinkimg?url=xxx",
+			"",
+			&LocationInfo{Latitude: 22.543099, Longitude: 114.057868, Label: "深圳市福田区福华路", PoiName: "深圳市福田区福华路", Url: mapUrl},
+		},
+	}
+	for _, c := range cases {
+		got, err := parseLocationMsg(c.content, c.oriContent, mapUrl)
+		if err != nil {
+			t.Errorf("parseLocationMsg(%q) error: %s", c.content, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseLocationMsg(%q) = %+v, want %+v", c.content, got, c.want)
+		}
+	}
+
+	if _, err := parseLocationMsg("深圳市", "", "http://apis.map.qq.com/"); err == nil {
+		t.Errorf("parseLocationMsg expected error")
+	}
+}
+
+func TestParseEmoticonMsg(t *testing.T) {
+	cases := []struct {
+		content      string
+		hasProductId bool
+		want         *EmoticonInfo
+	}{
+		{
+			`&lt;msg&gt;&lt;emoji fromusername = "wxid_a" tousername = "wxid_b" type="2" idbuffer="media:0_0" md5="e2d1b9e5c2a4f1ab36a0c1b4b2f0d8a1" len = "120436" productid="" androidmd5="e2d1b9e5c2a4f1ab36a0c1b4b2f0d8a1" androidlen="120436" s60v3md5 = "e2d1b9e5c2a4f1ab36a0c1b4b2f0d8a1" s60v3len="120436" s60v5md5 = "e2d1b9e5c2a4f1ab36a0c1b4b2f0d8a1" s60v5len="120436" cdnurl = "http://emoji.qpic.cn/wx_emoji/abc/" designerid = "" thumburl = "" encrypturl = "" aeskey= "" width= "240" height= "240" &gt;&lt;/emoji&gt; &lt;/msg&gt;`,
+			false,
+			&EmoticonInfo{Md5: "e2d1b9e5c2a4f1ab36a0c1b4b2f0d8a1", CdnUrl: "http://emoji.qpic.cn/wx_emoji/abc/", Width: 240, Height: 240, IsCustom: true},
+		},
+		{
+			`@123:<br/>&lt;msg&gt;&lt;emoji md5="0a1b" productid="com.tencent.xin.emoticon.person.stiker_1" cdnurl="http://emoji.qpic.cn/wx_emoji/def/" width="120" height="120"&gt;&lt;/emoji&gt;&lt;/msg&gt;`,
+			true,
+			&EmoticonInfo{Md5: "0a1b", CdnUrl: "http://emoji.qpic.cn/wx_emoji/def/", Width: 120, Height: 120, ProductId: "com.tencent.xin.emoticon.person.stiker_1"},
+		},
+		{
+			// 商店表情的消息内容可能为空
+			"",
+			true,
+			&EmoticonInfo{},
+		},
+	}
+	for _, c := range cases {
+		got, err := parseEmoticonMsg(c.content, c.hasProductId)
+		if err != nil {
+			t.Errorf("parseEmoticonMsg(%q) error: %s", c.content, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseEmoticonMsg(%q) = %+v, want %+v", c.content, got, c.want)
+		}
+	}
+
+	if _, err := parseEmoticonMsg("[表情]", false); err == nil {
+		t.Errorf("parseEmoticonMsg expected error")
+	}
+}
+
+func TestParseRevokeMsg(t *testing.T) {
+	msg := &MsgServices{msgCache: newMsgCache(defaultMsgCacheSize)}
+	msg.msgCache.Put(Message{MsgId: 1234567890123456789, Content: "原始消息"})
+
+	cases := []struct {
+		content string
+		want    *RevokeInfo
+	}{
+		{
+			`&lt;sysmsg type="revokemsg"&gt;&lt;revokemsg&gt;&lt;session&gt;wxid_a&lt;/session&gt;&lt;oldmsgid&gt;1001&lt;/oldmsgid&gt;&lt;msgid&gt;1234567890123456789&lt;/msgid&gt;&lt;replacemsg&gt;&lt;![CDATA["张三" 撤回了一条消息]]&gt;&lt;/replacemsg&gt;&lt;/revokemsg&gt;&lt;/sysmsg&gt;`,
+			&RevokeInfo{MsgId: 1234567890123456789, ReplaceMsg: `"张三" 撤回了一条消息`, OriginMsg: &Message{MsgId: 1234567890123456789, Content: "原始消息"}},
+		},
+		{
+			// 群组消息带有发送者前缀，原始消息不在缓存中
+			`@123:<br/>&lt;sysmsg type="revokemsg"&gt;&lt;revokemsg&gt;&lt;msgid&gt;42&lt;/msgid&gt;&lt;replacemsg&gt;&lt;![CDATA["李四" 撤回了一条消息]]&gt;&lt;/replacemsg&gt;&lt;/revokemsg&gt;&lt;/sysmsg&gt;`,
+			&RevokeInfo{MsgId: 42, ReplaceMsg: `"李四" 撤回了一条消息`},
+		},
+	}
+	for _, c := range cases {
+		got, err := msg.parseRevokeMsg(c.content)
+		if err != nil {
+			t.Errorf("parseRevokeMsg(%q) error: %s", c.content, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseRevokeMsg(%q) = %+v, want %+v", c.content, got, c.want)
+		}
+	}
+
+	for _, content := range []string{
+		"你撤回了一条消息",
+		`&lt;sysmsg type="revokemsg"&gt;&lt;revokemsg&gt;&lt;msgid&gt;&lt;/msgid&gt;&lt;/revokemsg&gt;&lt;/sysmsg&gt;`,
+	} {
+		if _, err := msg.parseRevokeMsg(content); err == nil {
+			t.Errorf("parseRevokeMsg(%q) expected error", content)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+)
+
+// 按名称查找接收者id，依次匹配备注名、昵称或群名、微信号
+// 已经是id的名称及filehelper等特殊用户直接返回
+func (init *InitService) ResolveRecipient(name string) (string, error) {
+	if name == "" {
+		return "", errors.RecipientNotFoundError.New().WithDesc("接收者为空")
+	}
+	if strings.HasPrefix(name, "@") {
+		return name, nil
+	}
+	if _, ok := global.Common.SpecialUsers[name]; ok {
+		return name, nil
+	}
+
+	matchers := []func(member TinyMemberInfo) string{
+		func(member TinyMemberInfo) string { return member.RemarkName },
+		func(member TinyMemberInfo) string { return member.NickName },
+		func(member TinyMemberInfo) string { return member.Alias },
+	}
+	members := init.BaseUserData.Members()
+	for _, matcher := range matchers {
+		userNames := []string{}
+		for userName, member := range members {
+			value := matcher(member)
+			// 昵称中可能包含表情标签
+			if value != "" && (value == name || util.NormalizeContent(value) == name) {
+				userNames = append(userNames, userName)
+			}
+		}
+		switch len(userNames) {
+		case 0:
+			continue
+		case 1:
+			return userNames[0], nil
+		}
+		sort.Strings(userNames)
+		return "", errors.RecipientAmbiguousError.New().WithDesc(fmt.Sprintf("[name:%s, candidates:%s]", name, strings.Join(userNames, ",")))
+	}
+	return "", errors.RecipientNotFoundError.New().WithDesc(fmt.Sprintf("[name:%s]", name))
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 消息发送后可撤回的时限
+	revokeWindow = 2 * time.Minute
+	// 发送记录保留时间
+	sentRecordKeepTime = time.Hour
+)
+
+// 最近发送的消息发送时间，用于撤回前判断是否超时
+type sentRecords struct {
+	lock    sync.Mutex
+	records map[string]time.Time
+}
+
+func newSentRecords() *sentRecords {
+	return &sentRecords{
+		records: make(map[string]time.Time),
+	}
+}
+
+func (s *sentRecords) Put(result SendResult) {
+	if result.MsgID == "" {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for msgId, sendTime := range s.records {
+		if time.Since(sendTime) > sentRecordKeepTime {
+			delete(s.records, msgId)
+		}
+	}
+	s.records[result.MsgID] = result.SendTime
+}
+
+// 获取本次登录发送的消息的发送时间
+func (s *sentRecords) Get(msgId string) (time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sendTime, ok := s.records[msgId]
+	return sendTime, ok
+}
+
+// 从客户端消息id的毫秒时间戳前缀解析发送时间，非newClientMsgId生成的id解析失败
+func clientMsgIdTime(clientMsgId string) (time.Time, bool) {
+	if len(clientMsgId) < 13 {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(clientMsgId[:13], 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	sendTime := time.Unix(0, ms*int64(time.Millisecond))
+	if sendTime.After(time.Now().Add(time.Minute)) {
+		return time.Time{}, false
+	}
+	return sendTime, true
+}
+
+// 判断消息是否已超过撤回时限，优先使用发送记录，没有记录时从clientMsgId解析，无法确定发送时间时视为超时
+func (msg *MsgServices) revokeExpired(svrMsgId, clientMsgId string) bool {
+	sendTime, ok := msg.sentRecords.Get(svrMsgId)
+	if !ok {
+		sendTime, ok = clientMsgIdTime(clientMsgId)
+	}
+	return !ok || time.Since(sendTime) > revokeWindow
+}
+
+// 撤回已发送的消息，svrMsgId和clientMsgId来自发送结果的MsgID和ClientMsgId
+// 超过2分钟或无法确定发送时间时返回RevokeTimeoutError
+func (msg *MsgServices) Revoke(toUserName, svrMsgId, clientMsgId string) error {
+	if msg.revokeExpired(svrMsgId, clientMsgId) {
+		return errors.RevokeTimeoutError.New().WithDesc(fmt.Sprintf("[msgId:%s]", svrMsgId))
+	}
+	toUserName, err := msg.InitService.ResolveRecipient(toUserName)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("lang", global.Common.Lang)
+	params.Set("pass_ticket", msg.LoginData.BaseRequest.PassTicket)
+
+	reqBodyParam, _ := json.Marshal(struct {
+		BaseRequest *BaseRequest
+		ClientMsgId string
+		SvrMsgId    string
+		ToUserName  string
+	}{
+		BaseRequest: msg.LoginData.BaseRequest,
+		ClientMsgId: clientMsgId,
+		SvrMsgId:    svrMsgId,
+		ToUserName:  toUserName,
+	})
+
+	urlPath := fmt.Sprintf("%s?%s", global.Common.WXUrlBase.WebWXRevokeMsgUrl, params.Encode())
+	resp, err := msg.Request.Request(http.MethodPost, urlPath, reqBodyParam, util.JSON_HEADER)
+	if err != nil {
+		logrus.Warningf("撤回消息失败[msgId:%s, err:%s]", svrMsgId, err.Error())
+		return errors.RevokeError.New().WithDesc(err.Error())
+	}
+
+	respData := struct {
+		BaseResponse BaseResponse
+		Introduction string
+		SysWording   string
+	}{}
+	err = json.Unmarshal(resp, &respData)
+	if err != nil {
+		logrus.Warningf("撤回消息返回数据解析失败[resp:%s, err:%s]", string(resp), err.Error())
+		return errors.RevokeError.New().WithMsg("撤回消息返回数据解析失败").WithDesc(err.Error())
+	}
+	if respData.BaseResponse.Ret != 0 {
+		logrus.Warningf("撤回消息失败,接口请求失败[code:%d,err:%s,wording:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg, respData.SysWording)
+		return errors.RevokeError.New().WithCode(respData.BaseResponse.Ret).
+			WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s,wording:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg, respData.SysWording))
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/constants/types"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	scheduleFileName = "send.schedule"
+	// 超过执行时间1分钟视为错过执行
+	scheduleMissedThreshold = time.Minute
+	// 没有定时任务时的检查间隔
+	scheduleIdleInterval = time.Minute
+)
+
+// 定时消息
+type Schedule struct {
+	// 任务id，添加时为空会自动生成
+	Id string
+	// 接收者，可以是id或名称，发送时重新查找，建议使用名称以便重新登录后仍然有效
+	Recipients []string
+	// 消息内容
+	Content string
+	// 一次性发送时间，Cron为空时有效，早于当前时间且MissedPolicy不为补发时无法添加
+	RunAt time.Time
+	// cron表达式，格式为：分 时 日 月 周，如"30 9 * * 1-5"表示工作日9:30
+	Cron string
+	// 错过执行时间的处理策略
+	MissedPolicy types.MissedRunPolicy
+	// 下次执行时间
+	NextRun time.Time
+	// 上次执行时间
+	LastRun time.Time
+}
+
+// 定时消息调度，任务保存在文件中，重启后继续执行，到期的消息放入发送队列发送
+type Scheduler struct {
+	file      string
+	lock      sync.Mutex
+	schedules map[string]*Schedule
+	// 任务变化时通知
+	notify chan struct{}
+}
+
+// 创建定时消息调度并加载文件中保存的任务，需在添加任务及调度协程启动前完成加载
+func NewScheduler(rootDir string) *Scheduler {
+	s := &Scheduler{
+		file:      rootDir + "/" + scheduleFileName,
+		schedules: make(map[string]*Schedule),
+		notify:    make(chan struct{}, 1),
+	}
+	if err := s.Load(); err != nil {
+		logrus.Warningf("加载定时任务失败[err:%s]", err.Error())
+	}
+	return s
+}
+
+// 从文件加载定时任务，文件不存在时为空
+func (s *Scheduler) Load() error {
+	if _, err := os.Stat(s.file); os.IsNotExist(err) {
+		return nil
+	}
+	buf, err := util.LoadCacheData(s.file)
+	if err != nil {
+		return err
+	}
+	schedules := []*Schedule{}
+	err = json.Unmarshal(buf, &schedules)
+	if err != nil {
+		logrus.Warningf("解析定时任务失败[file:%s, err:%s]", s.file, err.Error())
+		return err
+	}
+
+	s.lock.Lock()
+	for _, schedule := range schedules {
+		if _, ok := s.schedules[schedule.Id]; !ok {
+			s.schedules[schedule.Id] = schedule
+		}
+	}
+	s.lock.Unlock()
+	s.wake()
+	return nil
+}
+
+// 保存定时任务，调用方需持有锁
+func (s *Scheduler) save() {
+	buf, err := json.Marshal(s.list())
+	if err != nil {
+		logrus.Warningf("序列化定时任务失败[err:%s]", err.Error())
+		return
+	}
+	_ = util.SaveCacheData(buf, s.file)
+}
+
+func (s *Scheduler) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 按下次执行时间排序的任务列表，调用方需持有锁
+func (s *Scheduler) list() []Schedule {
+	result := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		result = append(result, *schedule)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NextRun.Before(result[j].NextRun)
+	})
+	return result
+}
+
+// 添加定时任务，Id已存在时替换原任务
+func (s *Scheduler) Add(schedule Schedule) (Schedule, error) {
+	if len(schedule.Recipients) == 0 || schedule.Content == "" {
+		return schedule, errors.ScheduleError.New().WithDesc("接收者和消息内容不能为空")
+	}
+	if schedule.Cron != "" {
+		cron, err := parseCron(schedule.Cron)
+		if err != nil {
+			return schedule, errors.ScheduleError.New().WithDesc(err.Error())
+		}
+		schedule.NextRun = cron.Next(time.Now())
+		if schedule.NextRun.IsZero() {
+			return schedule, errors.ScheduleError.New().WithDesc(fmt.Sprintf("cron表达式没有可执行的时间[cron:%s]", schedule.Cron))
+		}
+	} else if schedule.RunAt.IsZero() {
+		return schedule, errors.ScheduleError.New().WithDesc("需要设置发送时间或cron表达式")
+	} else if time.Since(schedule.RunAt) > scheduleMissedThreshold && schedule.MissedPolicy != types.MISSED_RUN_CATCH_UP {
+		// 按跳过策略将永远不会执行
+		return schedule, errors.ScheduleError.New().WithDesc(fmt.Sprintf("发送时间已过[runAt:%s]", schedule.RunAt.Format(time.RFC3339)))
+	} else {
+		schedule.NextRun = schedule.RunAt
+	}
+	if schedule.Id == "" {
+		schedule.Id = newClientMsgId()
+	}
+
+	s.lock.Lock()
+	s.schedules[schedule.Id] = &schedule
+	s.save()
+	s.lock.Unlock()
+	s.wake()
+	return schedule, nil
+}
+
+// 删除定时任务
+func (s *Scheduler) Remove(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return false
+	}
+	delete(s.schedules, id)
+	s.save()
+	return true
+}
+
+// 获取所有定时任务
+func (s *Scheduler) Schedules() []Schedule {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.list()
+}
+
+// 获取到期的任务，并计算下次执行时间，没有到期任务时返回等待时间
+// 错过执行时间的任务按策略决定是否执行
+func (s *Scheduler) due() ([]Schedule, time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	wait := scheduleIdleInterval
+	result := []Schedule{}
+	changed := false
+	for id, schedule := range s.schedules {
+		if schedule.NextRun.After(now) {
+			if d := schedule.NextRun.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+		changed = true
+
+		if now.Sub(schedule.NextRun) > scheduleMissedThreshold && schedule.MissedPolicy == types.MISSED_RUN_SKIP {
+			logrus.Warningf("定时任务错过执行时间,跳过[id:%s, nextRun:%s]", id, schedule.NextRun.Format(time.RFC3339))
+		} else {
+			schedule.LastRun = now
+			result = append(result, *schedule)
+		}
+
+		if schedule.Cron == "" {
+			delete(s.schedules, id)
+			continue
+		}
+		cron, err := parseCron(schedule.Cron)
+		if err != nil {
+			logrus.Warningf("定时任务cron表达式错误,已删除[id:%s, err:%s]", id, err.Error())
+			delete(s.schedules, id)
+			continue
+		}
+		// 错过多次时也只补发一次
+		schedule.NextRun = cron.Next(now)
+		if schedule.NextRun.IsZero() {
+			delete(s.schedules, id)
+		} else if d := schedule.NextRun.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if changed {
+		s.save()
+	}
+	return result, wait
+}
+
+// 添加定时消息，会检查接收者是否存在
+func (msg *MsgServices) AddSchedule(schedule Schedule) (Schedule, error) {
+	for _, recipient := range schedule.Recipients {
+		if _, err := msg.InitService.ResolveRecipient(recipient); err != nil {
+			return schedule, err
+		}
+	}
+	return msg.Scheduler.Add(schedule)
+}
+
+// 执行定时任务，到期的消息放入发送队列
+func (msg *MsgServices) ScheduleDaemon() {
+	for {
+		schedules, wait := msg.Scheduler.due()
+		for _, schedule := range schedules {
+			for _, recipient := range schedule.Recipients {
+				msg.SendQueue.Push(SendMessage{
+					ToUserName: recipient,
+					Content:    schedule.Content,
+					LocalID:    newClientMsgId(),
+				})
+			}
+		}
+		if len(schedules) > 0 {
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-msg.Scheduler.notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 客户端消息id序号，保证同一毫秒内生成的id不重复
+var clientMsgSeq uint32
+
+// 待投递的发送响应最大数量，接收方长时间不读取时丢弃新的响应
+const sendRespMaxPending = 1000
+
+// 发送频率控制，发送队列和群发共用，保证两次发送之间至少间隔指定时长
+type sendLimiter struct {
+	lock sync.Mutex
+	last time.Time
+}
+
+// 等待到可以发送的时间，并记录本次发送时间
+func (l *sendLimiter) wait(interval time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if d := time.Until(l.last.Add(interval)); d > 0 {
+		time.Sleep(d)
+	}
+	l.last = time.Now()
+}
+
+// 等待发送结果
+type sendOutcome struct {
+	result SendResult
+	err    error
+}
+
+// 生成客户端消息id，格式同网页版：毫秒时间戳+4位序号
+func newClientMsgId() string {
+	seq := atomic.AddUint32(&clientMsgSeq, 1) % 10000
+	return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10) + fmt.Sprintf("%04d", seq)
+}
+
+// 同步发送文本消息，通过LocalID关联发送结果，返回服务端消息id
+// ctx取消时从发送队列中移除消息；若消息已经开始发送则无法取消，返回的结果中带有LocalID，
+// 可通过MsgSendResp或GetDeadLetters确认最终结果
+func (msg *MsgServices) Send(ctx context.Context, message SendMessage) (SendResult, error) {
+	if message.LocalID == "" {
+		message.LocalID = newClientMsgId()
+	}
+	if err := ctx.Err(); err != nil {
+		return SendResult{}, err
+	}
+	waiter := make(chan sendOutcome, 1)
+	if !msg.addSendWaiter(message.LocalID, waiter) {
+		return SendResult{}, errors.SendError.New().WithDesc(fmt.Sprintf("LocalID重复[%s]", message.LocalID))
+	}
+	defer msg.removeSendWaiter(message.LocalID)
+
+	msg.SendQueue.Push(message)
+
+	select {
+	case outcome := <-waiter:
+		return outcome.result, outcome.err
+	case <-ctx.Done():
+		if msg.SendQueue.Remove(message.LocalID) {
+			return SendResult{}, ctx.Err()
+		}
+		logrus.Warningf("消息已开始发送,无法取消[localId:%s]", message.LocalID)
+		return SendResult{LocalID: message.LocalID, ClientMsgId: message.LocalID}, ctx.Err()
+	}
+}
+
+func (msg *MsgServices) addSendWaiter(localId string, waiter chan sendOutcome) bool {
+	msg.sendLock.Lock()
+	defer msg.sendLock.Unlock()
+	if _, ok := msg.sendWaiters[localId]; ok {
+		return false
+	}
+	msg.sendWaiters[localId] = waiter
+	return true
+}
+
+func (msg *MsgServices) removeSendWaiter(localId string) {
+	msg.sendLock.Lock()
+	defer msg.sendLock.Unlock()
+	delete(msg.sendWaiters, localId)
+}
+
+// 将发送结果通知给等待方，没有等待方时忽略
+func (msg *MsgServices) notifySendResult(localId string, result SendResult, err error) {
+	msg.sendLock.Lock()
+	waiter, ok := msg.sendWaiters[localId]
+	msg.sendLock.Unlock()
+	if ok {
+		waiter <- sendOutcome{result: result, err: err}
+	}
+}
+
+// 记录待投递的发送响应，积压超过上限时丢弃
+func (msg *MsgServices) pushSendResp(resp SendMessageResp) {
+	msg.sendRespLock.Lock()
+	if len(msg.sendResps) >= sendRespMaxPending {
+		msg.sendRespLock.Unlock()
+		logrus.Warningf("发送响应积压过多,已丢弃[localId:%s, msgId:%s]", resp.LocalID, resp.MsgID)
+		return
+	}
+	msg.sendResps = append(msg.sendResps, resp)
+	msg.sendRespLock.Unlock()
+
+	select {
+	case msg.sendRespNotify <- struct{}{}:
+	default:
+	}
+}
+
+// 按顺序将发送响应投递到MsgSendResp，接收方未读取时阻塞等待
+func (msg *MsgServices) sendRespDaemon() {
+	for range msg.sendRespNotify {
+		for {
+			msg.sendRespLock.Lock()
+			if len(msg.sendResps) == 0 {
+				msg.sendRespLock.Unlock()
+				break
+			}
+			resp := msg.sendResps[0]
+			msg.sendResps = msg.sendResps[1:]
+			msg.sendRespLock.Unlock()
+			msg.MsgSendResp <- resp
+		}
+	}
+}
+
+// 发送消息请求，检查接口返回码并转换为对应类型的错误
+func (msg *MsgServices) postSendMsg(urlPath, toUserName, clientMsgId string, message interface{}) (SendResult, error) {
+	reqBodyParam, _ := json.Marshal(struct {
+		BaseRequest *BaseRequest
+		Msg         interface{}
+		Scene       int
+	}{
+		BaseRequest: msg.LoginData.BaseRequest,
+		Msg:         message,
+	})
+
+	resp, err := msg.Request.Request(http.MethodPost, urlPath, reqBodyParam, util.JSON_HEADER)
+	if err != nil {
+		logrus.Warningf("消息发送失败[msg:%+v, err:%s]", message, err.Error())
+		return SendResult{}, errors.SendError.New().WithMsg("消息发送失败").WithDesc(err.Error())
+	}
+
+	respData := SendMessageResp{}
+	err = json.Unmarshal(resp, &respData)
+	if err != nil {
+		logrus.Warningf("消息发送返回数据解析失败[resp:%s, err:%s]", string(resp), err.Error())
+		return SendResult{}, errors.SendError.New().WithMsg("消息发送返回数据解析失败").WithDesc(err.Error())
+	}
+	if respData.BaseResponse.Ret != 0 {
+		logrus.Warningf("消息发送失败,接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg)
+		return SendResult{}, errors.RetError(respData.BaseResponse.Ret).New().WithCode(respData.BaseResponse.Ret).
+			WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
+	}
+
+	result := SendResult{
+		ToUserName:  toUserName,
+		MsgID:       respData.MsgID,
+		LocalID:     clientMsgId,
+		ClientMsgId: clientMsgId,
+		SendTime:    time.Now(),
+	}
+	msg.sentRecords.Put(result)
+	return result, nil
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 发送队列默认配置
+const (
+	defaultSendInterval    = time.Second
+	defaultSendMaxAttempts = 5
+	// 重试间隔从2秒开始翻倍，最长5分钟
+	sendRetryBaseInterval = 2 * time.Second
+	sendRetryMaxInterval  = 5 * time.Minute
+	// 触发频率限制时至少等待1分钟
+	sendFrequencyLimitInterval = time.Minute
+	// 队列为空时的检查间隔
+	sendQueueIdleInterval = time.Minute
+	sendQueueFileName     = "send.queue"
+)
+
+// 发送队列中的消息
+type QueuedMessage struct {
+	Message SendMessage
+	// 已尝试发送次数
+	Attempts int
+	// 下次发送时间
+	NextTime time.Time
+	// 最近一次发送失败原因
+	LastError string
+	// 入队时间
+	CreateTime time.Time
+}
+
+type sendQueueData struct {
+	Pending     []*QueuedMessage
+	DeadLetters []*QueuedMessage
+}
+
+// 持久化的消息发送队列，待发送和发送失败的消息保存在文件中，重启后继续发送
+type SendQueue struct {
+	file string
+	lock sync.Mutex
+	data sendQueueData
+	// 正在发送的消息
+	sending *QueuedMessage
+	// 有新消息入队时通知
+	notify chan struct{}
+}
+
+// 创建发送队列并加载文件中保存的消息，需在发送协程启动前完成加载，避免入队时覆盖未加载的文件
+func NewSendQueue(rootDir string) *SendQueue {
+	q := &SendQueue{
+		file:   rootDir + "/" + sendQueueFileName,
+		notify: make(chan struct{}, 1),
+	}
+	if err := q.Load(); err != nil {
+		logrus.Warningf("加载发送队列失败[err:%s]", err.Error())
+	}
+	return q
+}
+
+// 从文件加载队列，文件不存在时为空队列
+func (q *SendQueue) Load() error {
+	if _, err := os.Stat(q.file); os.IsNotExist(err) {
+		return nil
+	}
+	buf, err := util.LoadCacheData(q.file)
+	if err != nil {
+		return err
+	}
+	data := sendQueueData{}
+	err = json.Unmarshal(buf, &data)
+	if err != nil {
+		logrus.Warningf("解析发送队列失败[file:%s, err:%s]", q.file, err.Error())
+		return err
+	}
+
+	q.lock.Lock()
+	// 加载前已入队的消息排在后面
+	q.data.Pending = append(data.Pending, q.data.Pending...)
+	q.data.DeadLetters = append(data.DeadLetters, q.data.DeadLetters...)
+	q.lock.Unlock()
+	q.wake()
+	return nil
+}
+
+// 保存队列，调用方需持有锁
+func (q *SendQueue) save() {
+	buf, err := json.Marshal(q.data)
+	if err != nil {
+		logrus.Warningf("序列化发送队列失败[err:%s]", err.Error())
+		return
+	}
+	_ = util.SaveCacheData(buf, q.file)
+}
+
+func (q *SendQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 消息入队
+func (q *SendQueue) Push(message SendMessage) {
+	now := time.Now()
+	q.lock.Lock()
+	q.data.Pending = append(q.data.Pending, &QueuedMessage{
+		Message:    message,
+		NextTime:   now,
+		CreateTime: now,
+	})
+	q.save()
+	q.lock.Unlock()
+	q.wake()
+}
+
+// 获取最早入队且已到发送时间的消息，没有时返回距下一条消息可发送的等待时间
+func (q *SendQueue) next() (*QueuedMessage, time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := time.Now()
+	wait := sendQueueIdleInterval
+	for _, item := range q.data.Pending {
+		if !item.NextTime.After(now) {
+			q.sending = item
+			return item, 0
+		}
+		if d := item.NextTime.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return nil, wait
+}
+
+// 发送成功，移出队列
+func (q *SendQueue) done(item *QueuedMessage) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.sending = nil
+	q.data.Pending = removeQueuedMessage(q.data.Pending, item)
+	q.save()
+}
+
+// 发送失败，延迟后重试
+func (q *SendQueue) retry(item *QueuedMessage, err error, delay time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.sending = nil
+	item.Attempts++
+	item.LastError = err.Error()
+	item.NextTime = time.Now().Add(delay)
+	q.save()
+}
+
+// 发送失败且无法重试，移入死信列表
+func (q *SendQueue) dead(item *QueuedMessage, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.sending = nil
+	item.Attempts++
+	item.LastError = err.Error()
+	q.data.Pending = removeQueuedMessage(q.data.Pending, item)
+	q.data.DeadLetters = append(q.data.DeadLetters, item)
+	q.save()
+}
+
+// 取消待发送的消息，消息正在发送或已发送时返回false
+func (q *SendQueue) Remove(localId string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	item := findQueuedMessage(q.data.Pending, localId)
+	if item == nil || item == q.sending {
+		return false
+	}
+	q.data.Pending = removeQueuedMessage(q.data.Pending, item)
+	q.save()
+	return true
+}
+
+// 获取待发送的消息
+func (q *SendQueue) Pending() []QueuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return copyQueuedMessages(q.data.Pending)
+}
+
+// 获取发送失败的消息
+func (q *SendQueue) DeadLetters() []QueuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return copyQueuedMessages(q.data.DeadLetters)
+}
+
+// 将发送失败的消息重新加入待发送队列
+func (q *SendQueue) RetryDeadLetter(localId string) bool {
+	q.lock.Lock()
+	item := findQueuedMessage(q.data.DeadLetters, localId)
+	if item == nil {
+		q.lock.Unlock()
+		return false
+	}
+	q.data.DeadLetters = removeQueuedMessage(q.data.DeadLetters, item)
+	item.Attempts = 0
+	item.NextTime = time.Now()
+	q.data.Pending = append(q.data.Pending, item)
+	q.save()
+	q.lock.Unlock()
+	q.wake()
+	return true
+}
+
+// 删除发送失败的消息
+func (q *SendQueue) RemoveDeadLetter(localId string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	item := findQueuedMessage(q.data.DeadLetters, localId)
+	if item == nil {
+		return false
+	}
+	q.data.DeadLetters = removeQueuedMessage(q.data.DeadLetters, item)
+	q.save()
+	return true
+}
+
+func findQueuedMessage(list []*QueuedMessage, localId string) *QueuedMessage {
+	for _, item := range list {
+		if item.Message.LocalID == localId {
+			return item
+		}
+	}
+	return nil
+}
+
+func removeQueuedMessage(list []*QueuedMessage, item *QueuedMessage) []*QueuedMessage {
+	for i, v := range list {
+		if v == item {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+func copyQueuedMessages(list []*QueuedMessage) []QueuedMessage {
+	result := make([]QueuedMessage, 0, len(list))
+	for _, item := range list {
+		result = append(result, *item)
+	}
+	return result
+}
+
+// 计算重试间隔，按已尝试次数翻倍
+func sendRetryDelay(attempts int) time.Duration {
+	delay := sendRetryBaseInterval
+	for i := 0; i < attempts && delay < sendRetryMaxInterval; i++ {
+		delay *= 2
+	}
+	if delay > sendRetryMaxInterval {
+		delay = sendRetryMaxInterval
+	}
+	return delay
+}
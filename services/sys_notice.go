@@ -0,0 +1,162 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/oliverCJ/go-wechat/constants/types"
+	"github.com/sirupsen/logrus"
+)
+
+// 系统通知匹配规则，operator/members未匹配时表示当前登录用户，self匹配时成员中包含当前登录用户
+type sysNoticePattern struct {
+	event types.MsgEventType
+	re    *regexp.Regexp
+}
+
+var sysNoticePatterns = []sysNoticePattern{
+	// 成员加入
+	{types.MSG_EVENT_MEMBER_JOINED, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|你)邀请(?:(?P<self>你)和"(?P<members>.+?)"|"(?P<members>.+?)"|你)加入了群聊`)},
+	{types.MSG_EVENT_MEMBER_JOINED, regexp.MustCompile(`^"(?P<members>.+?)"通过扫描(?:"(?P<operator>.+?)"|你)分享的二维码加入群聊`)},
+	{types.MSG_EVENT_MEMBER_JOINED, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|You) invited (?:(?P<self>you) and "(?P<members>.+?)"|"(?P<members>.+?)"|you) to (?:join )?the group chat`)},
+	{types.MSG_EVENT_MEMBER_JOINED, regexp.MustCompile(`^"(?P<members>.+?)" joined (?:the )?group chat via (?:the )?QR [Cc]ode shared by (?:"(?P<operator>.+?)"|you)`)},
+	// 成员离开
+	{types.MSG_EVENT_MEMBER_LEFT, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|你)将"(?P<members>.+?)"移出了群聊`)},
+	{types.MSG_EVENT_MEMBER_LEFT, regexp.MustCompile(`^你被"(?P<operator>.+?)"移出群聊`)},
+	{types.MSG_EVENT_MEMBER_LEFT, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|You) removed "(?P<members>.+?)" from the group chat`)},
+	{types.MSG_EVENT_MEMBER_LEFT, regexp.MustCompile(`^You were removed from the group chat by "(?P<operator>.+?)"`)},
+	// 群聊改名
+	{types.MSG_EVENT_GROUP_RENAMED, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|你)修改群名为"(?P<name>.+?)"`)},
+	{types.MSG_EVENT_GROUP_RENAMED, regexp.MustCompile(`^(?:"(?P<operator>.+?)"|You) changed the group name to "(?P<name>.+?)"`)},
+	// 添加好友
+	{types.MSG_EVENT_FRIEND_ADDED, regexp.MustCompile(`^你已添加了(?P<members>.+?)，现在可以开始聊天了`)},
+	{types.MSG_EVENT_FRIEND_ADDED, regexp.MustCompile(`^(?P<members>.+?)刚刚把你添加到通讯录，现在可以开始聊天了`)},
+	{types.MSG_EVENT_FRIEND_ADDED, regexp.MustCompile(`^You have added (?P<members>.+?) as your (?:WeChat )?contact`)},
+	{types.MSG_EVENT_FRIEND_ADDED, regexp.MustCompile(`^(?P<members>.+?) just added you to (?:his/her )?contacts`)},
+}
+
+var (
+	// 系统通知中的链接等标签
+	sysNoticeTagRegexp = regexp.MustCompile(`<[^>]+>`)
+	// 多个成员昵称分隔符
+	sysNoticeMemberSep = regexp.MustCompile(`、|, `)
+	// 统一引号
+	sysNoticeQuoteReplacer = strings.NewReplacer("“", `"`, "”", `"`)
+)
+
+// 解析系统通知，识别成员变动、群聊改名、添加好友事件
+func (msg *MsgServices) parseSysNotice(message *Message) bool {
+	content := html.UnescapeString(message.Content)
+	content = sysNoticeTagRegexp.ReplaceAllString(content, "")
+	content = strings.TrimSpace(sysNoticeQuoteReplacer.Replace(content))
+
+	for _, pattern := range sysNoticePatterns {
+		matchResult := pattern.re.FindStringSubmatch(content)
+		if matchResult == nil {
+			continue
+		}
+
+		info := &SysNoticeInfo{}
+		withSelf := false
+		for i, name := range pattern.re.SubexpNames() {
+			switch name {
+			case "operator":
+				info.Operator = matchResult[i]
+			case "members":
+				// 同名分组只有一个会匹配
+				if matchResult[i] != "" {
+					info.Members = sysNoticeMemberSep.Split(matchResult[i], -1)
+				}
+			case "self":
+				withSelf = matchResult[i] != ""
+			case "name":
+				info.GroupName = matchResult[i]
+			}
+		}
+		if withSelf {
+			// 当前登录用户与其他成员一起加入
+			info.Members = append([]string{msg.UserData.UserInfo.NickName}, info.Members...)
+			info.MemberUserNames = make([]string, len(info.Members))
+			info.MemberUserNames[0] = msg.UserData.UserInfo.UserName
+		}
+
+		if pattern.event == types.MSG_EVENT_FRIEND_ADDED {
+			msg.resolveFriendNotice(message.FromUserName, info)
+		} else {
+			msg.resolveGroupNotice(pattern.event, message.FromUserName, info)
+		}
+
+		message.Event = pattern.event
+		message.SysNoticeInfo = info
+		message.FormatContent = content
+		return true
+	}
+	logrus.Debugf("未识别的系统通知[content:%s]", content)
+	return false
+}
+
+// 解析添加好友通知，通知由新好友的会话发出
+func (msg *MsgServices) resolveFriendNotice(userName string, info *SysNoticeInfo) {
+	info.Operator = msg.UserData.UserInfo.NickName
+	info.OperatorUserName = msg.UserData.UserInfo.UserName
+
+	err := msg.InitService.BatchGetContactInfo([]string{userName})
+	if err != nil {
+		logrus.Warningf("更新好友信息失败[user:%s, err:%s]", userName, err.Error())
+	}
+	if len(info.Members) == 0 {
+		if _, member := msg.InitService.SearchMemberInfo(userName, ""); member != nil {
+			info.Members = []string{member.NickName}
+		}
+	}
+	info.MemberUserNames = []string{userName}
+}
+
+// 解析群组通知，匹配相关成员并刷新群组信息
+func (msg *MsgServices) resolveGroupNotice(event types.MsgEventType, groupName string, info *SysNoticeInfo) {
+	self := msg.UserData.UserInfo
+	if info.Operator == "" {
+		info.Operator = self.NickName
+		info.OperatorUserName = self.UserName
+	}
+	if len(info.Members) == 0 && (event == types.MSG_EVENT_MEMBER_JOINED || event == types.MSG_EVENT_MEMBER_LEFT) {
+		info.Members = []string{self.NickName}
+		info.MemberUserNames = []string{self.UserName}
+	}
+	if len(info.MemberUserNames) != len(info.Members) {
+		info.MemberUserNames = make([]string, len(info.Members))
+	}
+
+	// 离开的成员在刷新后将无法找到，需要先匹配一次
+	msg.resolveNoticeMembers(groupName, info)
+
+	err := msg.InitService.BatchGetContactInfo([]string{groupName})
+	if err != nil {
+		logrus.Warningf("更新群组信息失败[group:%s, err:%s]", groupName, err.Error())
+	}
+
+	msg.resolveNoticeMembers(groupName, info)
+	if info.GroupName == "" {
+		if group, ok := msg.UserData.GetMember(groupName); ok {
+			info.GroupName = group.NickName
+		}
+	}
+}
+
+// 按昵称匹配通知中尚未找到的成员id
+func (msg *MsgServices) resolveNoticeMembers(groupName string, info *SysNoticeInfo) {
+	if info.OperatorUserName == "" {
+		if user, _ := msg.InitService.SearchMemberInfoByName(info.Operator, groupName); user != nil {
+			info.OperatorUserName = user.UserName
+		}
+	}
+	for i, name := range info.Members {
+		if info.MemberUserNames[i] != "" {
+			continue
+		}
+		if user, _ := msg.InitService.SearchMemberInfoByName(name, groupName); user != nil {
+			info.MemberUserNames[i] = user.UserName
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/oliverCJ/go-wechat/constants/types"
+	"github.com/oliverCJ/go-wechat/global"
+)
+
+const testGroupContact = `{"BaseResponse":{"Ret":0},"Count":1,"ContactList":[{"UserName":"@@g","NickName":"测试群","MemberList":[
+	{"UserName":"@me","NickName":"我"},
+	{"UserName":"@zs","NickName":"张三"},
+	{"UserName":"@ls","NickName":"李四"},
+	{"UserName":"@ww","NickName":"王五"}]}]}`
+
+// 创建使用本地接口的消息服务，批量获取联系人接口始终返回测试群组，使用完后需关闭返回的服务
+func newTestMsgServices(t *testing.T) (*MsgServices, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testGroupContact))
+	}))
+	global.Common.WXUrlBase.LoginContactBatchUrl = server.URL
+
+	initService := NewInitService(&BaseLoginData{BaseRequest: &BaseRequest{}})
+	initService.BaseUserData.UserInfo = User{UserName: "@me", NickName: "我"}
+	if err := initService.BatchGetContactInfo([]string{"@@g"}); err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return &MsgServices{
+		LoginData:   initService.LoginData,
+		UserData:    initService.BaseUserData,
+		InitService: initService,
+		msgCache:    newMsgCache(defaultMsgCacheSize),
+	}, server
+}
+
+func TestParseSysNotice(t *testing.T) {
+	msg, server := newTestMsgServices(t)
+	defer server.Close()
+	cases := []struct {
+		content string
+		event   types.MsgEventType
+		info    SysNoticeInfo
+	}{
+		{
+			`"张三"邀请"李四、王五"加入了群聊`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"李四", "王五"}, MemberUserNames: []string{"@ls", "@ww"}, GroupName: "测试群"},
+		},
+		{
+			`"张三"邀请你加入了群聊，群聊参与人还有：李四、王五`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"我"}, MemberUserNames: []string{"@me"}, GroupName: "测试群"},
+		},
+		{
+			`"张三"邀请你和"李四"加入了群聊`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"我", "李四"}, MemberUserNames: []string{"@me", "@ls"}, GroupName: "测试群"},
+		},
+		{
+			`你邀请"李四"加入了群聊  <_wc_custom_link_ href="weixin://revoke_invite">撤销</_wc_custom_link_>`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "我", OperatorUserName: "@me", Members: []string{"李四"}, MemberUserNames: []string{"@ls"}, GroupName: "测试群"},
+		},
+		{
+			`"李四"通过扫描"张三"分享的二维码加入群聊`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"李四"}, MemberUserNames: []string{"@ls"}, GroupName: "测试群"},
+		},
+		{
+			`"张三" invited you and "李四" to the group chat`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"我", "李四"}, MemberUserNames: []string{"@me", "@ls"}, GroupName: "测试群"},
+		},
+		{
+			`"张三" invited "李四, 王五" to the group chat`,
+			types.MSG_EVENT_MEMBER_JOINED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"李四", "王五"}, MemberUserNames: []string{"@ls", "@ww"}, GroupName: "测试群"},
+		},
+		{
+			`你将"李四"移出了群聊`,
+			types.MSG_EVENT_MEMBER_LEFT,
+			SysNoticeInfo{Operator: "我", OperatorUserName: "@me", Members: []string{"李四"}, MemberUserNames: []string{"@ls"}, GroupName: "测试群"},
+		},
+		{
+			`你被"张三"移出群聊`,
+			types.MSG_EVENT_MEMBER_LEFT,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", Members: []string{"我"}, MemberUserNames: []string{"@me"}, GroupName: "测试群"},
+		},
+		{
+			`"张三"修改群名为“新群名”`,
+			types.MSG_EVENT_GROUP_RENAMED,
+			SysNoticeInfo{Operator: "张三", OperatorUserName: "@zs", GroupName: "新群名"},
+		},
+	}
+	for _, c := range cases {
+		message := Message{FromUserName: "@@g", Content: c.content}
+		if !msg.parseSysNotice(&message) {
+			t.Errorf("parseSysNotice(%q) not matched", c.content)
+			continue
+		}
+		if message.Event != c.event {
+			t.Errorf("parseSysNotice(%q) event = %d, want %d", c.content, message.Event, c.event)
+		}
+		if !reflect.DeepEqual(*message.SysNoticeInfo, c.info) {
+			t.Errorf("parseSysNotice(%q) info = %#v, want %#v", c.content, *message.SysNoticeInfo, c.info)
+		}
+	}
+}
+
+func TestParseSysNoticeFriendAdded(t *testing.T) {
+	msg, server := newTestMsgServices(t)
+	defer server.Close()
+	for _, content := range []string{
+		"你已添加了李四，现在可以开始聊天了。",
+		"李四刚刚把你添加到通讯录，现在可以开始聊天了。",
+		"You have added 李四 as your WeChat contact. Start chatting!",
+	} {
+		message := Message{FromUserName: "@ls", Content: content}
+		if !msg.parseSysNotice(&message) {
+			t.Errorf("parseSysNotice(%q) not matched", content)
+			continue
+		}
+		info := message.SysNoticeInfo
+		if message.Event != types.MSG_EVENT_FRIEND_ADDED || !reflect.DeepEqual(info.Members, []string{"李四"}) ||
+			!reflect.DeepEqual(info.MemberUserNames, []string{"@ls"}) {
+			t.Errorf("parseSysNotice(%q) = %d %+v", content, message.Event, *info)
+		}
+	}
+}
+
+func TestParseSysNoticeUnknown(t *testing.T) {
+	msg, server := newTestMsgServices(t)
+	defer server.Close()
+	for _, content := range []string{
+		"群公告已更新",
+		"你撤回了一条消息",
+	} {
+		message := Message{FromUserName: "@@g", Content: content}
+		if msg.parseSysNotice(&message) {
+			t.Errorf("parseSysNotice(%q) matched event %d", content, message.Event)
+		}
+	}
+}
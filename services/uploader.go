@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/oliverCJ/go-wechat/constants/errors"
+	"github.com/oliverCJ/go-wechat/global"
+	"github.com/oliverCJ/go-wechat/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 默认上传分块大小
+	defaultUploadChunkSize = 512 * 1024
+	// 默认分块失败重试次数
+	defaultUploadRetryTimes = 3
+	// 默认重试间隔，每次重试递增
+	defaultUploadRetryInterval = 2 * time.Second
+	// 默认单个分块上传超时时间
+	defaultUploadTimeout = 5 * time.Minute
+)
+
+// 微信对各类型文件的大小限制
+var mediaSizeLimits = map[string]int64{
+	mediaTypePic:   10 * 1024 * 1024,
+	mediaTypeVideo: 20 * 1024 * 1024,
+	mediaTypeDoc:   100 * 1024 * 1024,
+}
+
+// 上传进度回调，sent为已上传字节数，total为文件总大小
+type UploadProgressFunc func(fileName string, sent, total int64)
+
+// 上传文件参数
+type UploadParam struct {
+	FileName     string
+	MediaType    string
+	FromUserName string
+	ToUserName   string
+}
+
+// 上传媒体文件请求参数
+type uploadMediaRequest struct {
+	UploadType    int
+	BaseRequest   *BaseRequest
+	ClientMediaId int64
+	TotalLen      int64
+	StartPos      int64
+	DataLen       int64
+	MediaType     int
+	FromUserName  string
+	ToUserName    string
+	FileMd5       string
+}
+
+// 上传媒体文件返回数据
+type uploadMediaResp struct {
+	BaseResponse      BaseResponse
+	MediaId           string
+	StartPos          int64
+	CDNThumbImgHeight int
+	CDNThumbImgWidth  int
+}
+
+// 媒体文件上传，支持分块重试和进度回调
+type MediaUploader struct {
+	// 基础登录数据
+	LoginData *BaseLoginData
+	// 请求资源
+	Request *util.Request
+	// 分块大小
+	ChunkSize int
+	// 分块失败重试次数
+	RetryTimes int
+	// 重试间隔，每次重试递增
+	RetryInterval time.Duration
+	// 上传进度回调
+	Progress UploadProgressFunc
+}
+
+func NewMediaUploader(loginData *BaseLoginData) *MediaUploader {
+	// 上传文件需要设置cookie
+	fileUrl, _ := url.Parse(global.HostFile)
+	request := util.NewRequestWithTimeout(defaultUploadTimeout)
+	request.Client.Jar.SetCookies(fileUrl, loginData.Cookie)
+
+	return &MediaUploader{
+		LoginData:     loginData,
+		Request:       request,
+		ChunkSize:     defaultUploadChunkSize,
+		RetryTimes:    defaultUploadRetryTimes,
+		RetryInterval: defaultUploadRetryInterval,
+	}
+}
+
+// 上传文件，返回MediaId及文件大小
+func (u *MediaUploader) Upload(param UploadParam, r io.Reader) (string, int64, error) {
+	source, totalLen, fileMd5, cleanup, err := prepareUploadSource(r)
+	if err != nil {
+		logrus.Warningf("读取上传文件失败[file:%s, err:%s]", param.FileName, err.Error())
+		return "", 0, errors.UploadError.New().WithMsg("读取上传文件失败").WithDesc(err.Error())
+	}
+	defer cleanup()
+
+	if totalLen == 0 {
+		return "", 0, errors.UploadError.New().WithMsg("上传文件为空").WithDesc(param.FileName)
+	}
+	if limit, ok := mediaSizeLimits[param.MediaType]; ok && totalLen > limit {
+		logrus.Warningf("上传文件超过大小限制[file:%s, size:%d, limit:%d]", param.FileName, totalLen, limit)
+		return "", 0, errors.UploadSizeError.New().WithDesc(fmt.Sprintf("[file:%s, size:%d, limit:%d]", param.FileName, totalLen, limit))
+	}
+
+	uploadRequest, _ := json.Marshal(uploadMediaRequest{
+		UploadType:    2,
+		BaseRequest:   u.LoginData.BaseRequest,
+		ClientMediaId: time.Now().UnixNano() / int64(time.Millisecond),
+		TotalLen:      totalLen,
+		StartPos:      0,
+		DataLen:       totalLen,
+		MediaType:     4,
+		FromUserName:  param.FromUserName,
+		ToUserName:    param.ToUserName,
+		FileMd5:       fileMd5,
+	})
+
+	chunkSize := u.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSize
+	}
+	chunks := int((totalLen + int64(chunkSize) - 1) / int64(chunkSize))
+	mimeType := mime.TypeByExtension(filepath.Ext(param.FileName))
+	buf := make([]byte, chunkSize)
+
+	var (
+		sent     int64
+		respData *uploadMediaResp
+	)
+	for chunk := 0; chunk < chunks; chunk++ {
+		n, err := io.ReadFull(source, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			logrus.Warningf("读取上传文件失败[file:%s, chunk:%d, err:%s]", param.FileName, chunk, err.Error())
+			return "", 0, errors.UploadError.New().WithMsg("读取上传文件失败").WithDesc(err.Error())
+		}
+		if mimeType == "" {
+			mimeType = http.DetectContentType(buf[:n])
+		}
+
+		fields := [][2]string{
+			{"id", "WU_FILE_0"},
+			{"name", param.FileName},
+			{"type", mimeType},
+			{"lastModifiedDate", time.Now().Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")},
+			{"size", strconv.FormatInt(totalLen, 10)},
+		}
+		if chunks > 1 {
+			fields = append(fields, [2]string{"chunks", strconv.Itoa(chunks)}, [2]string{"chunk", strconv.Itoa(chunk)})
+		}
+		fields = append(fields,
+			[2]string{"mediatype", param.MediaType},
+			[2]string{"uploadmediarequest", string(uploadRequest)},
+			[2]string{"webwx_data_ticket", u.getCookie("webwx_data_ticket")},
+			[2]string{"pass_ticket", u.LoginData.BaseRequest.PassTicket},
+		)
+
+		respData, err = u.uploadChunk(param.FileName, chunk, fields, buf[:n])
+		if err != nil {
+			return "", 0, err
+		}
+
+		sent += int64(n)
+		if u.Progress != nil {
+			u.Progress(param.FileName, sent, totalLen)
+		}
+	}
+
+	if respData == nil || respData.MediaId == "" {
+		logrus.Warningf("上传文件失败,没有获取到MediaId[file:%s]", param.FileName)
+		return "", 0, errors.UploadError.New().WithDesc("没有获取到MediaId")
+	}
+	return respData.MediaId, totalLen, nil
+}
+
+// 上传单个分块，失败时按递增间隔重试
+func (u *MediaUploader) uploadChunk(fileName string, chunk int, fields [][2]string, data []byte) (*uploadMediaResp, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, field := range fields {
+		_ = writer.WriteField(field[0], field[1])
+	}
+	part, err := writer.CreateFormFile("filename", fileName)
+	if err != nil {
+		return nil, errors.UploadError.New().WithMsg("创建上传数据失败").WithDesc(err.Error())
+	}
+	_, _ = part.Write(data)
+	_ = writer.Close()
+
+	urlPath := global.Common.WXUrlBase.WebWXUploadMediaUrl + "?f=json"
+	for retry := 0; ; retry++ {
+		respData, err := u.postChunk(urlPath, body.Bytes(), writer.FormDataContentType())
+		if err == nil {
+			return respData, nil
+		}
+		if retry >= u.RetryTimes {
+			logrus.Warningf("上传文件失败,重试次数已达上限[file:%s, chunk:%d, err:%s]", fileName, chunk, err.Error())
+			return nil, errors.UploadError.New().WithDesc(fmt.Sprintf("[file:%s, chunk:%d, retry:%d, err:%s]", fileName, chunk, retry, err.Error()))
+		}
+		logrus.Warningf("上传文件分块失败,准备重试[file:%s, chunk:%d, retry:%d, err:%s]", fileName, chunk, retry+1, err.Error())
+		time.Sleep(u.RetryInterval * time.Duration(retry+1))
+	}
+}
+
+// 请求上传接口
+func (u *MediaUploader) postChunk(urlPath string, body []byte, contentType string) (*uploadMediaResp, error) {
+	resp, err := u.Request.Request(http.MethodPost, urlPath, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	respData := new(uploadMediaResp)
+	err = json.Unmarshal(resp, respData)
+	if err != nil {
+		return nil, errors.UploadError.New().WithMsg("上传文件返回数据解析失败").WithDesc(fmt.Sprintf("[resp:%s, err:%s]", string(resp), err.Error()))
+	}
+	if respData.BaseResponse.Ret != 0 {
+		return nil, errors.UploadError.New().WithDesc(fmt.Sprintf("接口请求失败[code:%d,err:%s]", respData.BaseResponse.Ret, respData.BaseResponse.ErrMsg))
+	}
+	return respData, nil
+}
+
+// 获取登录cookie
+func (u *MediaUploader) getCookie(name string) string {
+	for _, cookie := range u.LoginData.Cookie {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// 流式计算文件md5及大小，reader不支持Seek时先写入临时文件
+func prepareUploadSource(r io.Reader) (io.Reader, int64, string, func(), error) {
+	hash := md5.New()
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			size, err := io.Copy(hash, seeker)
+			if err != nil {
+				return nil, 0, "", func() {}, err
+			}
+			_, err = seeker.Seek(start, io.SeekStart)
+			if err != nil {
+				return nil, 0, "", func() {}, err
+			}
+			return seeker, size, hex.EncodeToString(hash.Sum(nil)), func() {}, nil
+		}
+	}
+
+	tmpFile, err := ioutil.TempFile("", "go-wechat-upload-")
+	if err != nil {
+		return nil, 0, "", func() {}, err
+	}
+	cleanup := func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+	}
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if err != nil {
+		cleanup()
+		return nil, 0, "", func() {}, err
+	}
+	_, err = tmpFile.Seek(0, io.SeekStart)
+	if err != nil {
+		cleanup()
+		return nil, 0, "", func() {}, err
+	}
+	return tmpFile, size, hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
@@ -1,7 +1,10 @@
 package go_wechat
 
 import (
+	"context"
+	"io"
 	"os"
+	"time"
 
 	"github.com/oliverCJ/go-wechat/global"
 	"github.com/oliverCJ/go-wechat/services"
@@ -24,6 +27,36 @@ func SetCacheHistory(set bool) {
 	gw.SetCacheHistory(set)
 }
 
+// 设置是否忽略自己在其他设备发送的消息，开启后这些消息不会进入读取通道
+func SetIgnoreSelfMsg(set bool) {
+	gw.SetIgnoreSelfMsg(set)
+}
+
+// 设置上传文件进度回调
+func SetUploadProgress(progress services.UploadProgressFunc) {
+	gw.SetUploadProgress(progress)
+}
+
+// 设置上传文件单个分块超时时间
+func SetUploadTimeout(timeout time.Duration) {
+	gw.SetUploadTimeout(timeout)
+}
+
+// 设置两次发送之间的间隔，默认1秒
+func SetSendInterval(interval time.Duration) {
+	gw.SetSendInterval(interval)
+}
+
+// 设置单条消息最大发送次数，超过后移入死信列表，默认5次
+func SetSendMaxAttempts(attempts int) {
+	gw.SetSendMaxAttempts(attempts)
+}
+
+// 设置是否在消息投递到读取通道后自动将会话标记为已读
+func SetAutoMarkRead(set bool) {
+	gw.SetAutoMarkRead(set)
+}
+
 // 设置日志级别
 func SetLog(logLevel string, logOutChan chan string, logFile *os.File) {
 	gw.SetLog(logLevel, logOutChan, logFile)
@@ -48,7 +81,8 @@ func GetSendChan() chan<- services.SendMessage {
 	return gw.sendChan
 }
 
-// 获取发送消息响应通道操作符
+// 获取发送消息响应通道操作符，每条发送成功的文本消息对应一个响应
+// 响应按顺序投递，未读取的响应最多积压1000条，超过后丢弃并记录日志
 func GetSendRespChan() <-chan services.SendMessageResp {
 	return gw.sendChanResp
 }
@@ -78,9 +112,9 @@ func GetMPSubscribeMsgList() []services.MPSubscribeMsg {
 	return gw.userData.MPSubscribeMsgList
 }
 
-// 获取全局用户mao
+// 获取全局用户mao，返回的是副本
 func GetGlobalMemberMap() map[string]services.TinyMemberInfo {
-	return gw.userData.GlobalMemberMap
+	return gw.userData.Members()
 }
 
 // 获取登录用户信息
@@ -88,6 +122,126 @@ func GetUserInfo() services.User {
 	return gw.userData.UserInfo
 }
 
+// 获取发送失败的消息
+func GetDeadLetters() []services.QueuedMessage {
+	return gw.msgService.SendQueue.DeadLetters()
+}
+
+// 重新发送失败的消息
+func RetryDeadLetter(localId string) bool {
+	return gw.msgService.SendQueue.RetryDeadLetter(localId)
+}
+
+// 删除发送失败的消息
+func RemoveDeadLetter(localId string) bool {
+	return gw.msgService.SendQueue.RemoveDeadLetter(localId)
+}
+
+// 同步发送文本消息，返回包含服务端消息id的发送结果，ctx取消时尚未开始发送的消息不再发送
+func Send(ctx context.Context, message services.SendMessage) (services.SendResult, error) {
+	return gw.msgService.Send(ctx, message)
+}
+
+// 按备注名、昵称、微信号或群名查找接收者id
+func ResolveRecipient(name string) (string, error) {
+	return gw.msgService.InitService.ResolveRecipient(name)
+}
+
+// 发送@群组成员的消息，userNames为群组成员id
+func SendGroupMention(ctx context.Context, groupName, content string, userNames ...string) (services.SendResult, error) {
+	return gw.msgService.SendGroupMention(ctx, groupName, content, userNames...)
+}
+
+// 群发文本消息，返回每个接收者的发送结果，触发频率限制或登录失效时停止发送
+func Broadcast(ctx context.Context, content string, filter services.BroadcastFilter, option services.BroadcastOption) ([]services.BroadcastResult, error) {
+	return gw.msgService.Broadcast(ctx, content, filter, option)
+}
+
+// 添加定时消息，返回包含任务id和下次执行时间的任务
+func AddSchedule(schedule services.Schedule) (services.Schedule, error) {
+	return gw.msgService.AddSchedule(schedule)
+}
+
+// 删除定时消息
+func RemoveSchedule(id string) bool {
+	return gw.msgService.Scheduler.Remove(id)
+}
+
+// 获取所有定时消息
+func GetSchedules() []services.Schedule {
+	return gw.msgService.Scheduler.Schedules()
+}
+
+// 将会话标记为已读，userName可以是id或名称
+func MarkRead(userName string) error {
+	return gw.msgService.MarkRead(userName)
+}
+
+// 撤回已发送的消息，参数来自发送结果，超过2分钟无法撤回
+func Revoke(toUserName, svrMsgId, clientMsgId string) error {
+	return gw.msgService.Revoke(toUserName, svrMsgId, clientMsgId)
+}
+
+// 转发收到的消息，返回结果与toUserNames一一对应
+func Forward(message services.Message, toUserNames ...string) ([]services.SendResult, error) {
+	return gw.msgService.Forward(message, toUserNames...)
+}
+
+// 通过好友请求，ticket来自好友请求消息的RecommendInfo.Ticket
+func AcceptFriend(userName, ticket string) error {
+	return gw.msgService.AcceptFriend(userName, ticket)
+}
+
+// 发送好友请求
+func AddFriend(userName, verifyContent string) error {
+	return gw.msgService.AddFriend(userName, verifyContent)
+}
+
+// 下载表情消息中的表情文件
+func DownloadEmoticon(message services.Message, w io.Writer) error {
+	return gw.msgService.DownloadEmoticon(message, w)
+}
+
+// 通过md5发送表情，md5来自表情消息的EmoticonInfo.Md5
+func SendEmoticon(toUserName, md5 string) (services.SendResult, error) {
+	return gw.msgService.SendEmoticon(toUserName, md5)
+}
+
+// 发送图片，返回发送结果
+func SendImage(toUserName, filePath string) (services.SendResult, error) {
+	return gw.msgService.SendImage(toUserName, filePath)
+}
+
+// 从reader发送图片，返回发送结果
+func SendImageReader(toUserName, fileName string, r io.Reader) (services.SendResult, error) {
+	return gw.msgService.SendImageReader(toUserName, fileName, r)
+}
+
+// 发送视频，返回发送结果
+func SendVideo(toUserName, filePath string) (services.SendResult, error) {
+	return gw.msgService.SendVideo(toUserName, filePath)
+}
+
+// 从reader发送视频，返回发送结果
+func SendVideoReader(toUserName, fileName string, r io.Reader) (services.SendResult, error) {
+	return gw.msgService.SendVideoReader(toUserName, fileName, r)
+}
+
+// 发送文件，根据文件类型自动选择以图片、视频或文件附件发送，返回发送结果
+func SendFile(toUserName, filePath string) (services.SendResult, error) {
+	return gw.msgService.SendFile(toUserName, filePath)
+}
+
+// 从reader发送文件，根据文件类型自动选择以图片、视频或文件附件发送，返回发送结果
+func SendFileReader(toUserName, fileName string, r io.Reader) (services.SendResult, error) {
+	return gw.msgService.SendFileReader(toUserName, fileName, r)
+}
+
+// 注册自定义消息解析器，appMsgType为0时匹配该消息类型的所有消息
+func RegisterMsgDecoder(msgType, appMsgType int, decoder services.MsgDecoder) {
+	services.RegisterMsgDecoder(msgType, appMsgType, decoder)
+}
+
 // 登录
 func Login() (*services.LoginService, error) {
 	loginService := services.NewLoginService(gw.rootPath)
@@ -110,12 +264,14 @@ func ContactInit(loginService *services.LoginService) (*services.InitService, er
 }
 
 func MsgInit(initService *services.InitService) (*services.MsgServices, error) {
-	MsgService := services.NewMsgService(initService, gw.autoReplay, gw.readChan, gw.sendChan, gw.sendChanResp)
+	MsgService := services.NewMsgService(initService, gw.msgOption(), gw.readChan, gw.sendChan, gw.sendChanResp)
 
 	// 子协程检测并获取消息
 	go MsgService.SyncDaemon(gw.closeChan)
 	// 子协程检测并发送消息
 	go MsgService.SendMsgDaemon(gw.closeChan)
+	// 子协程执行定时消息
+	go MsgService.ScheduleDaemon()
 	return MsgService, nil
 }
 
@@ -123,7 +279,7 @@ func Start() error {
 	gw.Init()
 	if gw.hotReload {
 		// 加载并恢复场景
-		loginData, userData, ok, err := services.LoadLogin(gw.rootPath, gw.autoReplay, gw.readChan, gw.sendChan, gw.sendChanResp)
+		loginData, userData, ok, err := services.LoadLogin(gw.rootPath, gw.msgOption(), gw.readChan, gw.sendChan, gw.sendChanResp)
 		if err == nil && ok {
 			contactService := services.NewInitService(loginData)
 			contactService.BaseUserData = userData
@@ -134,6 +290,7 @@ func Start() error {
 			}
 			gw.userData = msgService.UserData
 			gw.loginData = msgService.LoginData
+			gw.msgService = msgService
 			return nil
 		}
 	}
@@ -151,6 +308,7 @@ func Start() error {
 	}
 	gw.userData = msgService.UserData
 	gw.loginData = msgService.LoginData
+	gw.msgService = msgService
 	return nil
 }
 
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// 网页版emoji表情，如<span class="emoji emoji1f604"></span>
+	emojiSpanRegexp = regexp.MustCompile(`<span class="emoji emoji([0-9a-fA-F]+)"></span>`)
+	// 网页版QQ表情，如<span class="emoji qqemoji13"></span>
+	qqEmojiSpanRegexp = regexp.MustCompile(`<span class="emoji qqemoji\d+"></span>`)
+	// 换行
+	brRegexp = regexp.MustCompile(`<br\s*/?>`)
+	// 不换行空格及@成员后的四分之一空格统一为普通空格
+	spaceReplacer = strings.NewReplacer("\u00a0", " ", "\u2005", " ")
+)
+
+/**
+ *  将网页版消息内容还原为普通文本
+ *  1. emoji标签转换为unicode表情
+ *  2. <br/>转换为换行
+ *  3. 还原所有html实体，特殊空格统一为普通空格
+ *  发送消息时直接使用普通文本，由服务端转义，不需要反向编码
+ */
+func NormalizeContent(content string) string {
+	content = emojiSpanRegexp.ReplaceAllStringFunc(content, func(span string) string {
+		code := emojiSpanRegexp.FindStringSubmatch(span)[1]
+		if emoji, ok := decodeEmojiCode(code); ok {
+			return emoji
+		}
+		return span
+	})
+	content = qqEmojiSpanRegexp.ReplaceAllString(content, "[表情]")
+	content = brRegexp.ReplaceAllString(content, "\n")
+	content = html.UnescapeString(content)
+	return spaceReplacer.Replace(content)
+}
+
+// 解析emoji编码，大于0xFFFF的码点为5位，其余为4位
+func decodeEmojiCode(code string) (string, bool) {
+	var builder strings.Builder
+	code = strings.ToLower(code)
+	for len(code) > 0 {
+		length := 4
+		if len(code) >= 5 && code[0] == '1' {
+			length = 5
+		}
+		if len(code) < length {
+			return "", false
+		}
+		point, err := strconv.ParseInt(code[:length], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		builder.WriteRune(rune(point))
+		code = code[length:]
+	}
+	return builder.String(), true
+}
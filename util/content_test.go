@@ -0,0 +1,25 @@
+package util
+
+import "testing"
+
+func TestNormalizeContent(t *testing.T) {
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{"hello", "hello"},
+		{`<span class="emoji emoji1f604"></span>`, "\U0001f604"},
+		{`<span class="emoji emoji1f1e81f1f3"></span>`, "\U0001f1e8\U0001f1f3"},
+		{`<span class="emoji emojizzzz"></span>`, `<span class="emoji emojizzzz"></span>`},
+		{`hi<span class="emoji qqemoji13"></span>`, "hi[表情]"},
+		{"a<br/>b<br>c", "a\nb\nc"},
+		{"&lt;a&gt; &amp; &quot;", `<a> & "`},
+		{"@张三 你好", "@张三 你好"},
+		{"a b", "a b"},
+	}
+	for _, c := range cases {
+		if got := NormalizeContent(c.content); got != c.want {
+			t.Errorf("NormalizeContent(%q) = %q, want %q", c.content, got, c.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -26,27 +27,56 @@ type Request struct {
 }
 
 func NewRequest() *Request {
+	return NewRequestWithTimeout(1 * time.Minute)
+}
+
+// 创建指定超时时间的请求，用于上传文件等耗时较长的请求
+func NewRequestWithTimeout(timeout time.Duration) *Request {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil
 	}
 
-	transport := *(http.DefaultTransport.(*http.Transport))
-	transport.ResponseHeaderTimeout = 1 * time.Minute
-	transport.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: true,
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
 	}
 
 	return &Request{
 		Client: &http.Client{
-			Transport: &transport,
+			Transport: transport,
 			Jar:       jar,
-			Timeout:   1 * time.Minute,
+			Timeout:   timeout,
 		},
 	}
 }
 
+// 修改请求超时时间
+func (r *Request) SetTimeout(timeout time.Duration) {
+	r.Client.Timeout = timeout
+	if transport, ok := r.Client.Transport.(*http.Transport); ok {
+		transport.ResponseHeaderTimeout = timeout
+	}
+}
+
 func (r *Request) Request(method string, requestUrl string, data interface{}, contentType string) (result []byte, err error) {
+	result, _, err = r.RequestWithStatus(method, requestUrl, data, contentType)
+	return
+}
+
+// 发起请求并返回http状态码，用于需要判断下载是否成功的请求
+func (r *Request) RequestWithStatus(method string, requestUrl string, data interface{}, contentType string) (result []byte, statusCode int, err error) {
 	var (
 		resp = &http.Response{}
 		req  = &http.Request{}
@@ -63,14 +93,19 @@ func (r *Request) Request(method string, requestUrl string, data interface{}, co
 		paramsString = string(data.([]byte))
 	}
 
-	logrus.Debugf("向微信API发起请求:[url:%s, method:%s, params:%s]", requestUrl, method, paramsString)
+	if isTextContentType(contentType) {
+		logrus.Debugf("向微信API发起请求:[url:%s, method:%s, params:%s]", requestUrl, method, paramsString)
+	} else {
+		// 上传文件等二进制内容只记录长度
+		logrus.Debugf("向微信API发起请求:[url:%s, method:%s, contentType:%s, length:%d]", requestUrl, method, contentType, len(paramsString))
+	}
 
 	switch method {
 	case http.MethodPost:
 		req, err = http.NewRequest(method, requestUrl, strings.NewReader(paramsString))
 		if err != nil {
 			logrus.Warningf("创建请求失败[err:%s]", err.Error())
-			return nil, err
+			return nil, 0, err
 		}
 
 	case http.MethodGet:
@@ -80,10 +115,10 @@ func (r *Request) Request(method string, requestUrl string, data interface{}, co
 		req, err = http.NewRequest(method, requestUrl, nil)
 		if err != nil {
 			logrus.Warningf("创建请求失败[err:%s]", err.Error())
-			return nil, err
+			return nil, 0, err
 		}
 	default:
-		return nil, errors.RequestError.New().WithDesc("错误的method")
+		return nil, 0, errors.RequestError.New().WithDesc("错误的method")
 	}
 
 	req.Header.Set("Content-Type", contentType)
@@ -93,7 +128,7 @@ func (r *Request) Request(method string, requestUrl string, data interface{}, co
 
 	if err != nil {
 		logrus.Errorf("请求微信服务器失败:[%s]", err.Error())
-		return nil, errors.RequestError.New().WithDesc(fmt.Sprintf("请求微信服务器失败:[%s]", err.Error()))
+		return nil, 0, errors.RequestError.New().WithDesc(fmt.Sprintf("请求微信服务器失败:[%s]", err.Error()))
 	}
 
 	defer resp.Body.Close()
@@ -101,12 +136,19 @@ func (r *Request) Request(method string, requestUrl string, data interface{}, co
 	resultBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		logrus.Errorf("获取微信API数据失败:[%s]", err.Error())
-		return nil, errors.RequestError.New().WithDesc(fmt.Sprintf("获取微信API数据失败:[%s]", err.Error()))
+		return nil, resp.StatusCode, errors.RequestError.New().WithDesc(fmt.Sprintf("获取微信API数据失败:[%s]", err.Error()))
 	}
 
 	result = resultBytes
+	statusCode = resp.StatusCode
 
 	logrus.Debugf("微信API返回成功，数据长度:%d", len(resultBytes))
 
 	return
 }
+
+// 判断是否为可以直接记录日志的文本内容
+func isTextContentType(contentType string) bool {
+	return contentType == "" || contentType == FORM_HEADER || strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "text/")
+}
@@ -64,3 +64,18 @@ func LoadCacheData(file string) ([]byte, error) {
 		return readContent, nil
 	}
 }
+
+// 保存数据到文件，先写入临时文件再替换，避免写入中断损坏已有数据
+func SaveCacheData(data []byte, file string) error {
+	tmpFile := file + ".tmp"
+	_, err := CacheData(data, os.O_RDWR|os.O_CREATE|os.O_TRUNC, tmpFile)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile, file)
+	if err != nil {
+		logrus.Warningf("替换文件失败[file:%s, err:%s]", file, err.Error())
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package go_wechat
 
 import (
 	"os"
+	"time"
 
 	"github.com/oliverCJ/go-wechat/services"
 	"github.com/oliverCJ/go-wechat/util"
@@ -26,10 +27,24 @@ type weChat struct {
 	closeChan chan bool
 	// 是否自动回复
 	autoReplay bool
+	// 是否忽略自己在其他设备发送的消息
+	ignoreSelfMsg bool
+	// 上传文件进度回调
+	uploadProgress services.UploadProgressFunc
+	// 上传文件单个分块超时时间
+	uploadTimeout time.Duration
+	// 两次发送之间的间隔
+	sendInterval time.Duration
+	// 单条消息最大发送次数
+	sendMaxAttempts int
+	// 是否自动将会话标记为已读
+	autoMarkRead bool
 	// 用户数据
 	userData *services.BaseUserData
 	// 登录数据
 	loginData *services.BaseLoginData
+	// 消息服务
+	msgService *services.MsgServices
 }
 
 func New() *weChat {
@@ -68,3 +83,41 @@ func (w *weChat) SetCacheHistory(set bool) {
 func (w *weChat) SetRootPath(dir string) {
 	w.rootPath = dir
 }
+
+func (w *weChat) SetIgnoreSelfMsg(set bool) {
+	w.ignoreSelfMsg = set
+}
+
+func (w *weChat) SetUploadProgress(progress services.UploadProgressFunc) {
+	w.uploadProgress = progress
+}
+
+func (w *weChat) SetUploadTimeout(timeout time.Duration) {
+	w.uploadTimeout = timeout
+}
+
+func (w *weChat) SetSendInterval(interval time.Duration) {
+	w.sendInterval = interval
+}
+
+func (w *weChat) SetSendMaxAttempts(attempts int) {
+	w.sendMaxAttempts = attempts
+}
+
+func (w *weChat) SetAutoMarkRead(set bool) {
+	w.autoMarkRead = set
+}
+
+// 消息服务配置
+func (w *weChat) msgOption() services.MsgOption {
+	return services.MsgOption{
+		AutoReply:       w.autoReplay,
+		IgnoreSelfMsg:   w.ignoreSelfMsg,
+		UploadProgress:  w.uploadProgress,
+		UploadTimeout:   w.uploadTimeout,
+		RootDir:         w.rootPath,
+		SendInterval:    w.sendInterval,
+		SendMaxAttempts: w.sendMaxAttempts,
+		AutoMarkRead:    w.autoMarkRead,
+	}
+}